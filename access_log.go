@@ -0,0 +1,166 @@
+// structured access log, one line per served request
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// request IDs accepted from clients or proxies, others are replaced
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestInfoKey struct{}
+
+// requestInfo is shared between access log middleware and handlers
+type requestInfo struct {
+	id string
+
+	// static file and probe requests are logged according to logging.access.sampling
+	sampled bool
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func requestInfoOf(r *http.Request) *requestInfo {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+// requestLogger returns logger adding request ID to log lines of a handler
+func requestLogger(r *http.Request) *log.Entry {
+	return log.WithField("request_id", requestInfoOf(r).id)
+}
+
+// markSampled excludes successful requests of handler from access log, apart from samples
+func markSampled(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestInfoOf(r).sampled = true
+		handler(w, r)
+	}
+}
+
+// isTrustedProxy checks address against server.trustedProxies
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	_, found := matchNetworks(ip, config.get().Server.TrustedProxies)
+	return found
+}
+
+// matchNetworks returns the first of addresses or CIDR ranges containing ip
+func matchNetworks(ip net.IP, networks []string) (string, bool) {
+	for _, entry := range networks {
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return entry, true
+			}
+			continue
+		}
+		if net.ParseIP(entry).Equal(ip) {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+// peerAddress returns address of the directly connected peer
+func peerAddress(r *http.Request) string {
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return address
+}
+
+// clientAddress returns remote address of request, taken from X-Forwarded-For
+// when the request came through trusted proxies
+func clientAddress(r *http.Request) string {
+	address := peerAddress(r)
+	if !isTrustedProxy(address) {
+		return address
+	}
+
+	// rightmost address not belonging to a trusted proxy is the client
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		address = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return address
+}
+
+// counter of sampled requests, for logging every n-th one
+var sampledRequests atomic.Uint64
+
+func shouldLogRequest(info *requestInfo, status int) bool {
+	access := config.get().Logging.Access
+	if !access.Enabled {
+		return false
+	}
+	if !info.sampled || status >= 400 {
+		return true
+	}
+	if access.Sampling <= 0 {
+		return false
+	}
+	return sampledRequests.Add(1)%uint64(access.Sampling) == 0
+}
+
+// accessLogHandler assigns request IDs and logs served requests
+func accessLogHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// IDs are only taken over from trusted proxies
+		info := &requestInfo{id: r.Header.Get(requestIDHeader)}
+		if !requestIDRegex.MatchString(info.id) || !isTrustedProxy(peerAddress(r)) {
+			info.id = newRequestID()
+		}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		r.Header.Set(requestIDHeader, info.id)
+		w.Header().Set(requestIDHeader, info.id)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+
+		if !shouldLogRequest(info, recorder.status) {
+			return
+		}
+		log.WithFields(log.Fields{
+			"request_id":  info.id,
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      recorder.status,
+			"bytes":       recorder.bytes,
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"remote":      clientAddress(r),
+			"user_agent":  r.UserAgent(),
+		}).Info("access")
+	})
+}
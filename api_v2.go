@@ -0,0 +1,267 @@
+// API v2, items as an ordered list with metadata, filtering and pagination
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	apiV2Path = "/api/v2"
+
+	// page size limit, also used when limit isn't set
+	apiV2MaxLimit = 1000
+)
+
+// ItemOrigin references the object an item was created from
+type ItemOrigin struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+// ItemV2 is a dashboard item as returned by API v2
+type ItemV2 struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Group       string            `json:"group"`
+	Description string            `json:"description"`
+	URL         string            `json:"url"`
+	Title       string            `json:"title"`
+	IconURL     string            `json:"iconURL"`
+	Labels      map[string]string `json:"labels"`
+	Tags        []string          `json:"tags"`
+	Source      string            `json:"source"`
+	Origin      *ItemOrigin       `json:"origin,omitempty"`
+	Cluster     string            `json:"cluster,omitempty"`
+	Crawl       ItemCrawl         `json:"crawl"`
+	Health      ItemHealth        `json:"health"`
+}
+
+type NamespaceV2 struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// API version and kind of objects each Kubernetes source watches
+var sourceOriginKinds = map[string][2]string{
+	sourceIngress:        {"networking.k8s.io/v1", "Ingress"},
+	sourceHTTPRoute:      {"gateway.networking.k8s.io/v1", "HTTPRoute"},
+	sourceKnativeService: {"serving.knative.dev/v1", "Service"},
+}
+
+// itemID returns a stable identifier of item name, safe to use in URL paths
+func itemID(name string) string {
+	return strToSha256(name)[:16]
+}
+
+// itemsV2 returns all items with their metadata, in no particular order
+func itemsV2() []ItemV2 {
+	items, _ := dashboardItems.snapshot()
+
+	origins := map[string]string{}
+	for ref, keys := range sourceItems.get() {
+		for _, key := range keys {
+			origins[key] = ref
+		}
+	}
+
+	result := make([]ItemV2, 0, len(items))
+	for name, entry := range items {
+		item := ItemV2{
+			ID:          itemID(name),
+			Name:        name,
+			Group:       entry.Namespace,
+			Description: entry.Description,
+			URL:         entry.URL,
+			Title:       entry.WebpageTitle,
+			IconURL:     entry.IconURL,
+			Labels:      entry.Labels,
+			Tags:        entry.Tags,
+			Source:      sourceStatic,
+		}
+		if ref, ok := origins[name]; ok {
+			parts := strings.SplitN(ref, "/", 3)
+			item.Source = parts[0]
+			if kind, ok := sourceOriginKinds[parts[0]]; ok {
+				item.Origin = &ItemOrigin{kind[0], kind[1], parts[1], parts[2]}
+				item.Cluster = clusterName()
+			}
+		}
+		item.Crawl = itemStates.read(name)
+		item.Health = dashboardItems.readHealth(name)
+		result = append(result, item)
+	}
+	return result
+}
+
+// itemsQuery holds parsed query parameters of the items list
+type itemsQuery struct {
+	namespaces []string
+	tags       []string
+	sources    []string
+	search     string
+	sort       []string
+	offset     int
+	limit      int
+}
+
+var itemsSortFields = map[string]func(a, b ItemV2) int{
+	"name":   func(a, b ItemV2) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+	"group":  func(a, b ItemV2) int { return strings.Compare(strings.ToLower(a.Group), strings.ToLower(b.Group)) },
+	"source": func(a, b ItemV2) int { return strings.Compare(a.Source, b.Source) },
+	"health": func(a, b ItemV2) int { return strings.Compare(a.Health.Status, b.Health.Status) },
+}
+
+func parseItemsQuery(values url.Values) (itemsQuery, error) {
+	query := itemsQuery{
+		namespaces: values["namespace"],
+		tags:       values["tag"],
+		sources:    values["source"],
+		search:     strings.ToLower(values.Get("q")),
+		sort:       []string{"group", "name"},
+		limit:      apiV2MaxLimit,
+	}
+
+	for _, source := range query.sources {
+		if !slices.Contains(sourceKinds, source) {
+			return query, fmt.Errorf("unknown source %q, allowed values: %s", source, strings.Join(sourceKinds, ", "))
+		}
+	}
+
+	if sortParam := values.Get("sort"); sortParam != "" {
+		query.sort = strings.Split(sortParam, ",")
+		for _, field := range query.sort {
+			if _, ok := itemsSortFields[strings.TrimPrefix(field, "-")]; !ok {
+				return query, fmt.Errorf("unknown sort field %q, allowed values: group, name, source, health, with optional '-' prefix", field)
+			}
+		}
+	}
+
+	for _, param := range []struct {
+		name   string
+		target *int
+	}{{"offset", &query.offset}, {"limit", &query.limit}} {
+		raw := values.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return query, fmt.Errorf("invalid %s %q, expected non-negative integer", param.name, raw)
+		}
+		*param.target = value
+	}
+	if query.limit == 0 || query.limit > apiV2MaxLimit {
+		query.limit = apiV2MaxLimit
+	}
+	return query, nil
+}
+
+func (query itemsQuery) matches(item ItemV2) bool {
+	if len(query.namespaces) > 0 && !slices.Contains(query.namespaces, item.Group) {
+		return false
+	}
+	if len(query.sources) > 0 && !slices.Contains(query.sources, item.Source) {
+		return false
+	}
+	for _, tag := range query.tags {
+		if !slices.Contains(item.Tags, tag) {
+			return false
+		}
+	}
+	if query.search != "" {
+		text := strings.ToLower(strings.Join([]string{item.Name, item.Group, item.Description, item.Title, item.URL}, "\n"))
+		if !strings.Contains(text, query.search) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply filters, sorts and paginates items, returning the page and total match count
+func (query itemsQuery) apply(items []ItemV2) ([]ItemV2, int) {
+	var matched []ItemV2
+	for _, item := range items {
+		if query.matches(item) {
+			matched = append(matched, item)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		for _, field := range query.sort {
+			compare := itemsSortFields[strings.TrimPrefix(field, "-")](matched[i], matched[j])
+			if strings.HasPrefix(field, "-") {
+				compare = -compare
+			}
+			if compare != 0 {
+				return compare < 0
+			}
+		}
+		// IDs keep order stable for equal items
+		return matched[i].ID < matched[j].ID
+	})
+
+	total := len(matched)
+	start := min(query.offset, total)
+	end := min(start+query.limit, total)
+	return append([]ItemV2{}, matched[start:end]...), total
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		requestLogger(r).Error("Error marshalling JSON: ", err)
+		http.Error(w, "Error marshalling JSON", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonData)
+}
+
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	requestLogger(r).Debug("Rejected ", r.URL.Path, " request: ", message)
+	writeJSON(w, r, status, map[string]string{"error": message})
+}
+
+// itemsV2Handler serves /api/v2/items, total count of matching items is in X-Total-Count header
+func itemsV2Handler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseItemsQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// statuses.go, results of outdated items follow as health events or in the next request
+	refreshOutdatedHealth(requestLogger(r))
+
+	page, total := query.apply(itemsV2())
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, r, http.StatusOK, page)
+}
+
+// namespacesV2Handler serves /api/v2/namespaces, groups with their item counts
+func namespacesV2Handler(w http.ResponseWriter, r *http.Request) {
+	counts := map[string]int{}
+	for _, item := range itemsV2() {
+		counts[item.Group]++
+	}
+
+	namespaces := make([]NamespaceV2, 0, len(counts))
+	for name, count := range counts {
+		namespaces = append(namespaces, NamespaceV2{name, count})
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+	writeJSON(w, r, http.StatusOK, namespaces)
+}
@@ -34,13 +34,12 @@ func getGeneratedIcon(entry *DashEntry, name string) {
 		return
 	}
 
-	entry.IconURL = "/avatars/" + strings.TrimSpace(name)
+	entry.IconURL = basePath + "/avatars/" + strings.TrimSpace(name)
 	return
 }
 
 func avatarHandler(w http.ResponseWriter, r *http.Request) {
-	log.Info("Avatar request: ", r.Method, " ", r.URL.Path)
-	name := strings.TrimPrefix(r.URL.Path, "/avatars/")
+	name := strings.TrimPrefix(r.URL.Path, basePath+"/avatars/")
 
 	// Use Cameron to generate avatars
 	buf := bytes.Buffer{}
@@ -0,0 +1,272 @@
+// per-item status check definitions, from annotations, static items and override rules
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	checkAnnotation = "casavue.app/check"
+
+	checkHTTP = "http"
+	checkTCP  = "tcp"
+	checkDNS  = "dns"
+	checkTLS  = "tls"
+
+	statusCheckDefaultTimeout = 30 * time.Second
+
+	// body read for bodyContains and bodyRegex
+	statusCheckMaxBody = 1 << 20
+)
+
+// status codes considered healthy when expectedStatus isn't set
+var defaultExpectedStatus = []string{"200", "401"}
+
+// "200", "200-399" or "2xx"
+var statusRangeRegex = regexp.MustCompile(`^([1-5][0-9]{2})(-([1-5][0-9]{2}))?$|^([1-5])xx$`)
+
+// HealthCheck configures how availability of an item is checked, empty fields keep
+// values of lower precedence definitions
+type HealthCheck struct {
+	Type            string            `yaml:"type,omitempty" description:"Check type: http request, tcp connect, dns resolution or tls handshake. http when empty." enum:"http,tcp,dns,tls"`
+	Address         string            `yaml:"address,omitempty" description:"host:port for tcp and tls checks, host name for dns checks. Item URL host and port when empty, port defaults to 443 for tls and 80 for tcp."`
+	URL             string            `yaml:"url,omitempty" description:"URL to check, or path resolved against item URL, e.g. /health. Item URL when empty."`
+	Method          string            `yaml:"method,omitempty" description:"HTTP method, GET when empty." enum:"GET,HEAD,POST,OPTIONS"`
+	ExpectedStatus  []string          `yaml:"expectedStatus,omitempty" description:"Healthy status codes or ranges, e.g. 200, 200-399 or 3xx. 200 and 401 when empty." pattern:"^([1-5][0-9]{2})(-([1-5][0-9]{2}))?$|^([1-5])xx$"`
+	BodyContains    string            `yaml:"bodyContains,omitempty" description:"Text the response body has to contain."`
+	BodyRegex       string            `yaml:"bodyRegex,omitempty" description:"Go regexp the response body has to match."`
+	Headers         map[string]string `yaml:"headers,omitempty" description:"Request headers, Host header sets the requested host name."`
+	Timeout         int               `yaml:"timeout,omitempty" description:"Seconds to wait for the response, 30 when empty." minimum:"0"`
+	FollowRedirects *bool             `yaml:"followRedirects,omitempty" description:"Follows redirects, checking the final response. True when empty."`
+}
+
+// merge returns check with fields set in other replacing its own
+func (check HealthCheck) merge(other *HealthCheck) HealthCheck {
+	if other == nil {
+		return check
+	}
+	if other.Type != "" {
+		check.Type = other.Type
+	}
+	if other.Address != "" {
+		check.Address = other.Address
+	}
+	if other.URL != "" {
+		check.URL = other.URL
+	}
+	if other.Method != "" {
+		check.Method = other.Method
+	}
+	if other.ExpectedStatus != nil {
+		check.ExpectedStatus = other.ExpectedStatus
+	}
+	if other.BodyContains != "" {
+		check.BodyContains = other.BodyContains
+	}
+	if other.BodyRegex != "" {
+		check.BodyRegex = other.BodyRegex
+	}
+	if other.Headers != nil {
+		headers := maps.Clone(check.Headers)
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		maps.Copy(headers, other.Headers)
+		check.Headers = headers
+	}
+	if other.Timeout != 0 {
+		check.Timeout = other.Timeout
+	}
+	if other.FollowRedirects != nil {
+		check.FollowRedirects = other.FollowRedirects
+	}
+	return check
+}
+
+// mergeChecks returns definition of an item with other merged in, nil when both are unset
+func mergeChecks(check *HealthCheck, other *HealthCheck) *HealthCheck {
+	if other == nil {
+		return check
+	}
+	var merged HealthCheck
+	if check != nil {
+		merged = *check
+	}
+	merged = merged.merge(other)
+	return &merged
+}
+
+// effectiveCheck returns item check definition layered on statusChecks.defaults
+func effectiveCheck(entry DashEntry) HealthCheck {
+	return config.get().StatusChecks.Defaults.merge(entry.Check)
+}
+
+// annotationCheck parses casavue.app/check annotation, a YAML or JSON check definition.
+// Secret references aren't resolved in annotations.
+func annotationCheck(annotations map[string]string) *HealthCheck {
+	value, ok := annotations[checkAnnotation]
+	if !ok {
+		return nil
+	}
+	log.Debug("Found check definition: ", value)
+
+	var check HealthCheck
+	decoder := yaml.NewDecoder(bytes.NewBufferString(value))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&check); err != nil {
+		log.Warn("Ignoring invalid ", checkAnnotation, " annotation: ", err)
+		return nil
+	}
+	if problems := validateCheck(check); len(problems) > 0 {
+		log.Warn("Ignoring invalid ", checkAnnotation, " annotation: ", strings.Join(problems, ", "))
+		return nil
+	}
+	return &check
+}
+
+// target returns URL to check, check URL may be a path relative to item URL
+func (check HealthCheck) target(itemURL string) (string, error) {
+	if check.URL == "" {
+		return itemURL, nil
+	}
+	base, err := url.Parse(itemURL)
+	if err != nil {
+		return "", err
+	}
+	reference, err := url.Parse(check.URL)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(reference).String(), nil
+}
+
+// address returns host and port to check, filled in from item URL
+func (check HealthCheck) address(itemURL string) (string, string, error) {
+	item, err := url.Parse(itemURL)
+	if err != nil {
+		return "", "", err
+	}
+	address := check.Address
+	if address == "" {
+		address = item.Host
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		// no port given
+		host, port = strings.Trim(address, "[]"), ""
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("missing host to check")
+	}
+	switch {
+	case port != "":
+	case item.Port() != "" && check.Address == "":
+		port = item.Port()
+	case check.Type == checkTLS || (check.Address == "" && item.Scheme == "https"):
+		port = "443"
+	default:
+		port = "80"
+	}
+	return host, port, nil
+}
+
+func (check HealthCheck) timeout() time.Duration {
+	if check.Timeout > 0 {
+		return time.Duration(check.Timeout) * time.Second
+	}
+	return statusCheckDefaultTimeout
+}
+
+func (check HealthCheck) followRedirects() bool {
+	return check.FollowRedirects == nil || *check.FollowRedirects
+}
+
+// expects reports whether status code is healthy
+func (check HealthCheck) expects(code int) bool {
+	expected := check.ExpectedStatus
+	if len(expected) == 0 {
+		expected = defaultExpectedStatus
+	}
+	for _, status := range expected {
+		low, high, ok := parseStatusRange(status)
+		if ok && code >= low && code <= high {
+			return true
+		}
+	}
+	return false
+}
+
+func parseStatusRange(status string) (int, int, bool) {
+	match := statusRangeRegex.FindStringSubmatch(strings.TrimSpace(status))
+	if match == nil {
+		return 0, 0, false
+	}
+	if match[4] != "" {
+		class, _ := strconv.Atoi(match[4])
+		return class * 100, class*100 + 99, true
+	}
+	low, _ := strconv.Atoi(match[1])
+	high := low
+	if match[3] != "" {
+		high, _ = strconv.Atoi(match[3])
+	}
+	return low, high, low <= high
+}
+
+// validateCheck returns problems of a check definition
+func validateCheck(check HealthCheck) []string {
+	var problems []string
+	if check.URL != "" {
+		if _, err := url.Parse(check.URL); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid check url %q: %s", check.URL, err))
+		} else if strings.Contains(check.URL, "://") {
+			if err := validateURL(check.URL); err != nil {
+				problems = append(problems, fmt.Sprintf("invalid check url %q: %s", check.URL, err))
+			}
+		}
+	}
+	switch check.Type {
+	case "", checkHTTP, checkTCP, checkDNS, checkTLS:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown check type %q, allowed values: http, tcp, dns, tls", check.Type))
+	}
+	if check.Address != "" {
+		if host, _, err := net.SplitHostPort(check.Address); err == nil && host == "" {
+			problems = append(problems, fmt.Sprintf("invalid check address %q: missing host", check.Address))
+		} else if strings.Contains(check.Address, "/") {
+			problems = append(problems, fmt.Sprintf("invalid check address %q: expected host or host:port", check.Address))
+		}
+	}
+	switch check.Method {
+	case "", "GET", "HEAD", "POST", "OPTIONS":
+	default:
+		problems = append(problems, fmt.Sprintf("unknown check method %q, allowed values: GET, HEAD, POST, OPTIONS", check.Method))
+	}
+	for _, status := range check.ExpectedStatus {
+		if _, _, ok := parseStatusRange(status); !ok {
+			problems = append(problems, fmt.Sprintf("invalid expected status %q: expected code, range like 200-399, or class like 2xx", status))
+		}
+	}
+	if check.BodyRegex != "" {
+		if _, err := regexp.Compile(check.BodyRegex); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid check bodyRegex: %s", err))
+		}
+	}
+	if check.Timeout < 0 {
+		problems = append(problems, fmt.Sprintf("check timeout %d has to be 0 or more", check.Timeout))
+	}
+	return problems
+}
@@ -0,0 +1,251 @@
+// response compression and caching headers for API and static files
+
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// file names with a content hash added by Vue build, e.g. app.1a2b3c4d.js
+var hashedAssetRegex = regexp.MustCompile(`\.[0-9a-f]{8,}\.[a-z0-9]+$`)
+
+// files rewritten at startup or on config reload
+var revalidatedFiles = map[string]bool{
+	"index.html":        true,
+	"config.json":       true,
+	"service-worker.js": true,
+	"manifest.json":     true,
+}
+
+// cacheControlFor returns Cache-Control value for a static file path
+func cacheControlFor(filePath string) string {
+	name := path.Base(filePath)
+	if strings.HasSuffix(filePath, "/") || revalidatedFiles[name] {
+		return "no-cache"
+	}
+	if hashedAssetRegex.MatchString(name) {
+		return "public, max-age=31536000, immutable"
+	}
+	return "public, max-age=3600"
+}
+
+// acceptsEncoding checks Accept-Encoding of request for encoding with non-zero quality,
+// "*" applies only to encodings not listed explicitly
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encoding:
+			return nonZeroQuality(params)
+		case "*":
+			wildcard = nonZeroQuality(params)
+		}
+	}
+	return wildcard
+}
+
+// nonZeroQuality reports whether parameters of an Accept-Encoding entry leave it acceptable
+func nonZeroQuality(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.TrimSpace(key) == "q" {
+			quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err == nil && quality > 0
+		}
+	}
+	return true
+}
+
+// precompressed file variants, in order of preference
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// servePrecompressed serves a .br or .gz variant of requested file, when one
+// accepted by the client exists and isn't older than the original
+func servePrecompressed(w http.ResponseWriter, r *http.Request, root string) bool {
+	if r.Header.Get("Range") != "" {
+		return false
+	}
+	name := path.Clean("/" + r.URL.Path)
+	original, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil || original.IsDir() {
+		return false
+	}
+
+	for _, variant := range precompressedEncodings {
+		if !acceptsEncoding(r, variant.encoding) {
+			continue
+		}
+		file, err := os.Open(filepath.Join(root, filepath.FromSlash(name)) + variant.extension)
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+		compressed, err := file.Stat()
+		if err != nil || compressed.ModTime().Before(original.ModTime()) {
+			continue
+		}
+
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("Content-Encoding", variant.encoding)
+		w.Header().Add("Vary", "Accept-Encoding")
+		http.ServeContent(w, r, name, original.ModTime(), file)
+		return true
+	}
+	return false
+}
+
+// encoders of dynamic responses, in order of preference
+var responseEncoders = []struct {
+	encoding string
+	pool     *sync.Pool
+}{
+	{"br", &sync.Pool{New: func() interface{} { return brotli.NewWriter(nil) }}},
+	{"gzip", &sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}},
+}
+
+// encodingWriter is implemented by both gzip and brotli writers
+type encodingWriter interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/schema+json" ||
+		mediaType == "application/manifest+json" ||
+		mediaType == "application/javascript" ||
+		mediaType == "image/svg+xml"
+}
+
+// encodedETag marks etag as belonging to the encoded variant, so caches don't mix variants
+func encodedETag(etag string, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// decodedETags strips encoding suffixes added by encodedETag from If-None-Match value
+func decodedETags(ifNoneMatch string, encoding string) string {
+	return strings.ReplaceAll(ifNoneMatch, "-"+encoding+`"`, `"`)
+}
+
+// compressResponseWriter encodes the body when its content type is worth compressing
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	pool        *sync.Pool
+	encoder     encodingWriter
+	wroteHeader bool
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if status == http.StatusNotModified {
+		// validated against the encoded variant
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", encodedETag(etag, w.encoding))
+		}
+	} else if status != http.StatusNoContent && header.Get("Content-Encoding") == "" && isCompressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", encodedETag(etag, w.encoding))
+		}
+		w.encoder = w.pool.Get().(encodingWriter)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressResponseWriter) close() {
+	if w.encoder != nil {
+		w.encoder.Close()
+		w.pool.Put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+// Unwrap gives http.ResponseController access to the original writer
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressHandler compresses responses with brotli or gzip, whichever the client
+// accepts, preferring brotli. ETags get the encoding appended.
+func compressHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			handler(w, r)
+			return
+		}
+		for _, encoder := range responseEncoders {
+			if !acceptsEncoding(r, encoder.encoding) {
+				continue
+			}
+			if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", decodedETags(ifNoneMatch, encoder.encoding))
+			}
+			writer := &compressResponseWriter{ResponseWriter: w, encoding: encoder.encoding, pool: encoder.pool}
+			defer writer.close()
+			handler(writer, r)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// staticFilesHandler serves compiled frontend with caching headers and precompressed variants
+func staticFilesHandler(root string) http.HandlerFunc {
+	fileServer := http.FileServer(http.Dir(root))
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cacheControlFor(r.URL.Path))
+		if servePrecompressed(w, r, root) {
+			return
+		}
+		compressHandler(fileServer.ServeHTTP)(w, r)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestCompressHandlerNegotiation(t *testing.T) {
+	body := strings.Repeat(`{"name":"grafana"}`, 100)
+	handler := compressHandler(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"42"`
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	})
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"":     func(r io.Reader) (io.Reader, error) { return r, nil },
+	}
+	cases := []struct {
+		acceptEncoding string
+		encoding       string
+		etag           string
+	}{
+		{"gzip, deflate, br", "br", `"42-br"`},
+		{"gzip", "gzip", `"42-gzip"`},
+		{"br;q=0, gzip", "gzip", `"42-gzip"`},
+		{"*;q=0, gzip", "gzip", `"42-gzip"`},
+		{"br;q=0, *", "gzip", `"42-gzip"`},
+		{"gzip;q=0, *", "br", `"42-br"`},
+		{"*", "br", `"42-br"`},
+		{"br; q=0.0, gzip;q=0", "", `"42"`},
+		{"", "", `"42"`},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1", nil)
+		request.Header.Set("Accept-Encoding", c.acceptEncoding)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+
+		if encoding := recorder.Header().Get("Content-Encoding"); encoding != c.encoding {
+			t.Errorf("%q: expected encoding %q, got %q", c.acceptEncoding, c.encoding, encoding)
+			continue
+		}
+		if etag := recorder.Header().Get("ETag"); etag != c.etag {
+			t.Errorf("%q: expected ETag %s, got %s", c.acceptEncoding, c.etag, etag)
+		}
+		reader, err := decoders[c.encoding](recorder.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded, err := io.ReadAll(reader); err != nil || string(decoded) != body {
+			t.Errorf("%q: body doesn't decode to the original: %v", c.acceptEncoding, err)
+		}
+
+		// the ETag of the variant validates against the same variant only
+		revalidation := httptest.NewRequest(http.MethodGet, "/api/v1", nil)
+		revalidation.Header.Set("Accept-Encoding", c.acceptEncoding)
+		revalidation.Header.Set("If-None-Match", c.etag)
+		recorder = httptest.NewRecorder()
+		handler(recorder, revalidation)
+		if recorder.Code != http.StatusNotModified || recorder.Header().Get("ETag") != c.etag {
+			t.Errorf("%q: expected 304 with ETag %s, got %d %s", c.acceptEncoding, c.etag, recorder.Code, recorder.Header().Get("ETag"))
+		}
+	}
+}
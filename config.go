@@ -5,12 +5,13 @@ package main
 import (
 	_ "embed"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 )
 
 //go:embed config/main.yaml
@@ -20,55 +21,158 @@ var default_config string
 var default_static_items string
 
 type Customization struct {
-	Name   string `json:"name"`
+	Name   string `json:"name" description:"Page title and PWA name."`
 	Colors struct {
-		Theme string `json:"theme"`
+		Theme string `json:"theme" description:"Main color, in hexadecimal format (e.g. \"#aabbcc\")." pattern:"^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$"`
 		Items struct {
-			Saturation int `json:"saturation"`
-			Lightness  int `json:"lightness"`
-		} `json:"items"`
+			Saturation int `json:"saturation" description:"Saturation of dashboard items colors." minimum:"0" maximum:"100"`
+			Lightness  int `json:"lightness" description:"Lightness of dashboard items colors, -1 follows browser theme setting." minimum:"-1" maximum:"100"`
+		} `json:"items" description:"Color settings for dashboard items."`
 	} `json:"colors"`
 }
 
 type Data struct {
 	Version       string        `json:"version"`
 	StaticMode    bool          `json:"staticMode"`
+	ProxyIcons    bool          `json:"proxyIcons"`
 	Customization Customization `json:"customization"`
 }
 
 type Filter struct {
-	Mode    string `yaml:"mode"`
-	Pattern string `yaml:"pattern"`
+	Mode    string `yaml:"mode" description:"Filter mode, 'ingressAnnotation' includes only resources with casavue.app/enable annotation." enum:"include,exclude,ingressAnnotation"`
+	Pattern string `yaml:"pattern" description:"Go regexp syntax pattern."`
+}
+
+// FilterRule matches items on any combination of fields, empty fields match everything
+type FilterRule struct {
+	Namespace   string            `yaml:"namespace,omitempty" description:"Regex matching item namespace."`
+	Name        string            `yaml:"name,omitempty" description:"Regex matching source resource name."`
+	Host        string            `yaml:"host,omitempty" description:"Regex matching item URL host."`
+	Labels      map[string]string `yaml:"labels,omitempty" description:"Labels which must be present, with value regex (\"\" matches any value)."`
+	Annotations map[string]string `yaml:"annotations,omitempty" description:"Annotations which must be present, with value regex (\"\" matches any value)."`
+	Source      []string          `yaml:"source,omitempty" description:"Item source kinds." enum:"static,ingress,httproute,knativeservice"`
+	Action      string            `yaml:"action" description:"Action taken for matching items." enum:"include,exclude" required:"true"`
 }
 
 type ContentFilters struct {
-	Namespace Filter `yaml:"namespace"`
-	Item      Filter `yaml:"item"`
+	Rules []FilterRule `yaml:"rules" description:"Ordered rules, the first rule matching an item decides. Items matching no rule are included."`
+
+	// legacy single rule filters, translated to rules when no rules are set
+	Namespace Filter `yaml:"namespace,omitempty" description:"Legacy namespace filter, use rules instead."`
+	Item      Filter `yaml:"item,omitempty" description:"Legacy item name filter, use rules instead."`
+}
+
+type AccessLog struct {
+	Enabled bool `yaml:"enabled" description:"Logs one line per served request."`
+	// static files and probes only, errors are always logged
+	Sampling int `yaml:"sampling" description:"Logs one of every n successful static file and probe requests, 0 logs none of them." minimum:"0"`
 }
 
 type Logging struct {
-	Level string `yaml:"level"`
+	Level  string    `yaml:"level" description:"Log level." enum:"debug,info,warn,error"`
+	Format string    `yaml:"format" description:"Log line format." enum:"text,json"`
+	Access AccessLog `yaml:"access" description:"Access log of served requests."`
+}
+
+// TLS enables HTTPS when certFile and keyFile are set
+type TLS struct {
+	CertFile     string `yaml:"certFile" description:"PEM certificate (chain) file, reloaded when changed."`
+	KeyFile      string `yaml:"keyFile" description:"PEM private key file, reloaded when changed."`
+	ClientCAFile string `yaml:"clientCAFile" description:"PEM CA bundle, when set clients must present a certificate signed by one of these CAs."`
+	RedirectPort int    `yaml:"redirectPort" description:"Port for plain HTTP listener redirecting to HTTPS, 0 to disable." minimum:"0" maximum:"65535"`
+}
+
+// SecurityHeaders configures headers added to all responses
+type SecurityHeaders struct {
+	Enabled               bool     `yaml:"enabled" description:"Adds security headers to all responses."`
+	ContentSecurityPolicy string   `yaml:"contentSecurityPolicy" description:"Content-Security-Policy replacing the generated one, which allows icons from origins of current items."`
+	ProxyIcons            bool     `yaml:"proxyIcons" description:"Serves item icons through CasaVue, so the generated policy allows images from CasaVue only."`
+	ReferrerPolicy        string   `yaml:"referrerPolicy" description:"Referrer-Policy header value, empty to leave it out." enum:",no-referrer,no-referrer-when-downgrade,origin,origin-when-cross-origin,same-origin,strict-origin,strict-origin-when-cross-origin,unsafe-url"`
+	FrameAncestors        []string `yaml:"frameAncestors" description:"CSP frame-ancestors sources allowed to embed CasaVue, e.g. 'self' or https://home.example.com. Embedding is denied when empty."`
+	HSTSMaxAge            int      `yaml:"hstsMaxAge" description:"Strict-Transport-Security max-age in seconds, sent with native TLS only, 0 to disable." minimum:"0"`
+	HSTSIncludeSubdomains bool     `yaml:"hstsIncludeSubdomains" description:"Adds includeSubDomains to Strict-Transport-Security."`
+}
+
+type Server struct {
+	Listen   string          `yaml:"listen" description:"Address for the HTTP server to listen on, empty for all interfaces."`
+	Port     int             `yaml:"port" description:"Port for the HTTP server to listen on." minimum:"1" maximum:"65535"`
+	BasePath string          `yaml:"basePath" description:"URL path CasaVue is served at, e.g. /dashboard, empty when served at root." pattern:"^(/[^?#]*)?$"`
+	TLS      TLS             `yaml:"tls" description:"Native HTTPS serving."`
+	Headers  SecurityHeaders `yaml:"headers" description:"Security headers of responses."`
+	// X-Forwarded-For and X-Request-ID are only honoured from these
+	TrustedProxies []string `yaml:"trustedProxies" description:"Addresses or CIDR ranges of reverse proxies whose X-Forwarded-For and X-Request-ID headers are trusted."`
+}
+
+type Cluster struct {
+	Name string `yaml:"name" description:"Cluster name shown with Kubernetes items, kubeconfig context name when empty."`
+}
+
+type StatusHistory struct {
+	Samples int    `yaml:"samples" description:"Check results kept per item, statistics cover at most samples times interval." minimum:"0"`
+	File    string `yaml:"file" description:"File history is saved to every minute and on shutdown, empty keeps it in memory only."`
+}
+
+type StatusChecks struct {
+	Enabled     bool          `yaml:"enabled" description:"Checks availability of all items in the background."`
+	Interval    int           `yaml:"interval" description:"Seconds between checks of an item." minimum:"1"`
+	Jitter      int           `yaml:"jitter" description:"Up to this many seconds are randomly added to each interval, spreading checks over time." minimum:"0"`
+	Concurrency int           `yaml:"concurrency" description:"Maximum number of checks running at once." minimum:"1"`
+	Defaults    HealthCheck   `yaml:"defaults" description:"Check settings of all items, fields set on items and by override rules take precedence."`
+	History     StatusHistory `yaml:"history" description:"History of check results, for uptime and latency statistics."`
+	// enforced when connecting, after name resolution
+	DeniedNetworks []string `yaml:"deniedNetworks" description:"Addresses or CIDR ranges status checks never connect to, e.g. loopback, link-local and cloud metadata addresses."`
+}
+
+type Metrics struct {
+	Enabled bool `yaml:"enabled" description:"Exposes Prometheus metrics at /metrics."`
 }
 
 // Config represents the overall structure of the YAML file
 type Config struct {
-	Customization         Customization  `yaml:"customization"`
-	Content_filters       ContentFilters `yaml:"content_filters"`
-	Allow_skip_tls_verify bool           `yaml:"allow_skip_tls_verify"`
+	APIVersion            string         `yaml:"apiVersion,omitempty" override:"-" description:"Configuration file layout version, older versions are upgraded on load. casavue.app/v1 when not set." enum:"casavue.app/v1"`
+	Customization         Customization  `yaml:"customization" description:"Appearance settings."`
+	Content_filters       ContentFilters `yaml:"content_filters" description:"Including or excluding items."`
+	Allow_skip_tls_verify bool           `yaml:"allow_skip_tls_verify" description:"Allows connections to servers with an invalid TLS certificate."`
 	Logging               Logging        `yaml:"logging"`
+	Server                Server         `yaml:"server"`
+	Metrics               Metrics        `yaml:"metrics"`
+	StatusChecks          StatusChecks   `yaml:"statusChecks" description:"Availability checks of item URLs."`
+	Cluster               Cluster        `yaml:"cluster"`
+	Overrides             Overrides      `yaml:"overrides" description:"Per-item overrides, for items which can't be annotated."`
 }
 
 // Item represents a single item in the YAML structure
 type Item struct {
-	Name        string `yaml:"name"`
-	Namespace   string `yaml:"namespace"`
-	Description string `yaml:"description"`
-	URL         string `yaml:"url"`
-	Icon        string `yaml:"icon"`
+	Name        string       `yaml:"name" description:"The name of the item to be displayed." required:"true"`
+	Namespace   string       `yaml:"namespace" description:"The category in which the item should be placed." required:"true"`
+	Description string       `yaml:"description" description:"Item description."`
+	URL         string       `yaml:"url" description:"URL to which the item should point to." format:"uri" required:"true"`
+	Icon        string       `yaml:"icon" description:"Item icon URL override."`
+	Check       *HealthCheck `yaml:"check,omitempty" description:"Status check settings of the item."`
 }
 
 type StaticItems struct {
-	Items []Item `yaml:"items"`
+	APIVersion string `yaml:"apiVersion,omitempty" description:"Configuration file layout version, casavue.app/v1 when not set." enum:"casavue.app/v1"`
+	Items      []Item `yaml:"items" description:"Static dashboard items."`
+}
+
+// thread safe store for the active configuration
+type ConfigStore struct {
+	sync.RWMutex
+	value Config
+}
+
+func (cs *ConfigStore) get() Config {
+	cs.RLock()
+	result := cs.value
+	cs.RUnlock()
+	return result
+}
+
+func (cs *ConfigStore) set(value Config) {
+	cs.Lock()
+	cs.value = value
+	cs.Unlock()
 }
 
 func loadConfig() {
@@ -80,12 +184,39 @@ func loadConfig() {
 	// create config if not found (first run)
 	initConfig()
 
-	// load default config values
-	err := yaml.Unmarshal([]byte(default_config), &config)
+	newConfig, err := readConfigFile()
 	if err != nil {
-		log.Fatal("Error unpacking default config values:", err)
+		log.Fatal(err)
 	}
+	readiness.set(readyConfig, true, "loaded from "+configFilePath)
+	applyConfig(newConfig)
+
+	// read staticItems file
+	yfile, err := ioutil.ReadFile(itemsFilePath)
+	if err != nil {
+		log.Warning("Error reading staticItems file:", err)
+	}
+
+	if err := problemsError(validateStaticItems(itemsFilePath, yfile)); err != nil {
+		log.Fatal(err)
+	}
+
+	// unpack staticItems YAML
+	staticItems, _, _ = decodeStaticItems(itemsFilePath, yfile)
 
+	writeFrontendConfig()
+
+	readStaticItems()
+}
+
+// readConfigFile parses main.yaml on top of the default config values,
+// then applies environment variable and command-line flag overrides
+// and resolves secret references
+func readConfigFile() (Config, error) {
+	return readMergedConfig(true)
+}
+
+func readMergedConfig(resolveSecrets bool) (Config, error) {
 	// read config file
 	yfile, err := ioutil.ReadFile(configFilePath)
 	if err != nil {
@@ -93,55 +224,82 @@ func loadConfig() {
 	}
 
 	// unpack config YAML
-	err = yaml.Unmarshal(yfile, &config)
-	if err != nil {
-		log.Fatal("Error unpacking config file:", err)
+	newConfig, root, problems := decodeMainConfig(configFilePath, yfile)
+	if root == nil {
+		return newConfig, problemsError(problems)
 	}
 
-	// set log level according to config
-	log.Info("Setting log level to: ", config.Logging.Level)
-	switch config.Logging.Level {
+	// config_overrides.go
+	if err := applyConfigOverrides(&newConfig); err != nil {
+		return newConfig, err
+	}
+
+	// secrets.go
+	if resolveSecrets {
+		problems = append(problems, resolveConfigSecrets(configFilePath, &newConfig)...)
+	}
+
+	// refuse bad config up front, before anything gets applied
+	problems = append(problems, validateConfigValues(configFilePath, root, newConfig)...)
+	if err := problemsError(problems); err != nil {
+		return newConfig, err
+	}
+	return newConfig, nil
+}
+
+func parseLogLevel(level string) (log.Level, error) {
+	switch level {
 	case "debug":
-		log.SetLevel(log.DebugLevel)
+		return log.DebugLevel, nil
 	case "info":
-		log.SetLevel(log.InfoLevel)
+		return log.InfoLevel, nil
 	case "warn":
-		log.SetLevel(log.WarnLevel)
+		return log.WarnLevel, nil
 	case "error":
-		log.SetLevel(log.ErrorLevel)
-	default:
-		log.Fatal("Invalid log level")
+		return log.ErrorLevel, nil
 	}
+	return log.InfoLevel, fmt.Errorf("invalid log level: %q", level)
+}
 
-	// set HTTP TLS verify mode
-	initHttpClient(config.Allow_skip_tls_verify)
+// applyConfig makes newConfig the active configuration
+func applyConfig(newConfig Config) {
+	previous := config.get()
 
-	// read staticItems file
-	yfile, err = ioutil.ReadFile(itemsFilePath)
+	// set log level according to config
+	log.Info("Setting log level to: ", newConfig.Logging.Level)
+	level, err := parseLogLevel(newConfig.Logging.Level)
 	if err != nil {
-		log.Warning("Error reading staticItems file:", err)
+		log.Fatal(err)
+	}
+	log.SetLevel(level)
+	if newConfig.Logging.Format == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	} else {
+		log.SetFormatter(&log.TextFormatter{})
 	}
 
-	// unpack staticItems YAML
-	err = yaml.Unmarshal(yfile, &staticItems)
-	if err != nil {
-		log.Fatal("Error unpacking staticFiles file:", err)
+	// set HTTP TLS verify mode
+	if httpClient.Load() == nil || previous.Allow_skip_tls_verify != newConfig.Allow_skip_tls_verify {
+		initHttpClient(newConfig.Allow_skip_tls_verify)
 	}
 
+	config.set(newConfig)
+}
+
+// writeFrontendConfig creates config file for Vue frontend
+func writeFrontendConfig() {
 	data := Data{
 		Version:       version,
 		StaticMode:    *staticMode,
-		Customization: config.Customization,
+		ProxyIcons:    config.get().Server.Headers.ProxyIcons && !*staticMode,
+		Customization: config.get().Customization,
 	}
 
-	// create config file for Vue frontend
 	jsonFile, err := json.MarshalIndent(data, "", " ")
 	if err != nil {
 		log.Fatal("Error creating Vue config file: ", err)
 	}
 	_ = ioutil.WriteFile(compiledVuePath+"/config.json", jsonFile, 0644)
-
-	readStaticItems()
 }
 
 func initConfig() {
@@ -170,24 +328,23 @@ func initConfig() {
 	}
 }
 
-func readStaticItems() {
+func readStaticItems() []string {
+	var added []string
+
 	// add static entries from config file
 	for _, staticItem := range staticItems.Items {
+		entry := DashEntry{staticItem.Namespace, staticItem.Description, staticItem.URL, "", staticItem.Icon, make(map[string]string), nil, staticItem.Check}
 
-		// apply filter on namespaces
-		if applyFilter(config.Content_filters.Namespace.Pattern, config.Content_filters.Namespace.Mode, staticItem.Namespace) {
-			log.Debug("Skipping namespace '" + staticItem.Namespace + "' due to pattern")
-			continue
-		}
+		// overrides.go
+		entries := prepareEntries(func(DashEntry) filterSubject {
+			return staticFilterSubject(staticItem)
+		}, map[string]DashEntry{staticItem.Name: entry})
 
-		// apply filter on item names
-		if applyFilter(config.Content_filters.Item.Pattern, config.Content_filters.Item.Mode, staticItem.Name) {
-			log.Debug("Skipping item '" + staticItem.Name + "' due to pattern")
-			continue
+		for _, name := range sourceItems.replace(sourceRef(sourceStatic, staticItem.Namespace, staticItem.Name), entries) {
+			added = append(added, name)
+			log.Debug("Added static entry: ", name)
 		}
-
-		dashboardItems.write(staticItem.Name, DashEntry{staticItem.Namespace, staticItem.Description, staticItem.URL, "", staticItem.Icon, make(map[string]string)})
-		log.Debug("Added static entry: ", staticItem.Name)
 	}
 	log.Info("Loaded static entries from configuration.")
+	return added
 }
@@ -0,0 +1,334 @@
+// configuration files versioning and migrations between versions
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// version of files without apiVersion, they predate versioning
+const initialConfigVersion = "casavue.app/v1"
+
+// configMigration upgrades a document from one version to the next one, or rewrites
+// deprecated keys within a version when from and to are the same
+type configMigration struct {
+	from string
+	to   string
+
+	// apply rewrites the document node in place, returning descriptions of changes
+	apply func(root *yaml.Node) ([]string, error)
+}
+
+// configLayout is the current version of a file and the migrations leading to it
+type configLayout struct {
+	current string
+
+	// in order, when changing the file layout, bump current and append a
+	// migration from the previous version
+	migrations []configMigration
+}
+
+var mainConfigLayout = configLayout{"casavue.app/v1", []configMigration{
+	{"casavue.app/v1", "casavue.app/v1", migrateLegacyFilters},
+}}
+
+var staticItemsLayout = configLayout{"casavue.app/v1", nil}
+
+// migrateLegacyFilters replaces content_filters namespace and item filters with the
+// equivalent rules. Files setting both are left for validation to report.
+func migrateLegacyFilters(root *yaml.Node) ([]string, error) {
+	filtersNode := findNode(root, "content_filters")
+	if filtersNode == nil || filtersNode.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	var filters ContentFilters
+	if err := filtersNode.Decode(&filters); err != nil {
+		return nil, err
+	}
+	legacy := findNode(filtersNode, "namespace") != nil || findNode(filtersNode, "item") != nil
+	if !legacy || len(filters.Rules) > 0 {
+		return nil, nil
+	}
+
+	// content_filters.go
+	rules := translateLegacyFilters(filters)
+	var rulesNode yaml.Node
+	if err := rulesNode.Encode(rules); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		rulesNode.Style = yaml.FlowStyle
+	}
+
+	// comments of replaced keys are kept above rules, problems in rules are reported
+	// at the first replaced key
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "rules"}
+	var content []*yaml.Node
+	for i := 0; i+1 < len(filtersNode.Content); i += 2 {
+		switch filtersNode.Content[i].Value {
+		case "namespace", "item", "rules":
+			if comment := filtersNode.Content[i].HeadComment; comment != "" {
+				keyNode.HeadComment = strings.TrimSpace(keyNode.HeadComment + "\n" + comment)
+			}
+			if keyNode.Line == 0 {
+				keyNode.Line, rulesNode.Line = filtersNode.Content[i].Line, filtersNode.Content[i].Line
+			}
+			continue
+		}
+		content = append(content, filtersNode.Content[i], filtersNode.Content[i+1])
+	}
+	filtersNode.Content = append(content, keyNode, &rulesNode)
+
+	return []string{fmt.Sprintf("content_filters namespace and item filters replaced with %d equivalent rules", len(rules))}, nil
+}
+
+// setMappingValue sets key in mapping node, adding it at the top when missing
+func setMappingValue(mapping *yaml.Node, key string, value string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1].SetString(value)
+			return
+		}
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if len(mapping.Content) > 0 {
+		// the comment on top of the file stays there
+		keyNode.HeadComment, mapping.Content[0].HeadComment = mapping.Content[0].HeadComment, ""
+	}
+	mapping.Content = append([]*yaml.Node{keyNode, valueNode}, mapping.Content...)
+}
+
+// migrateDocument upgrades root to the current version of layout, returning the
+// original version and descriptions of applied changes
+func migrateDocument(root *yaml.Node, layout configLayout) (string, []string, error) {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		// empty or non-mapping documents are reported by strict decoding
+		return layout.current, nil, nil
+	}
+
+	original := initialConfigVersion
+	if node := findNode(root, "apiVersion"); node != nil {
+		original = node.Value
+	}
+
+	// migrations are in order, each one applies to the version the previous one left
+	var changes []string
+	version := original
+	for _, migration := range layout.migrations {
+		if migration.from != version {
+			continue
+		}
+		applied, err := migration.apply(root)
+		if err != nil {
+			return original, nil, fmt.Errorf("migrating %s -> %s: %w", migration.from, migration.to, err)
+		}
+		step := migration.from + " -> " + migration.to
+		if migration.from == migration.to {
+			step = migration.from
+		}
+		for _, change := range applied {
+			changes = append(changes, step+": "+change)
+		}
+		version = migration.to
+	}
+	if version != layout.current {
+		return original, nil, fmt.Errorf("unsupported apiVersion %q, supported: %s and older", original, layout.current)
+	}
+
+	if len(changes) > 0 {
+		setMappingValue(root.Content[0], "apiVersion", layout.current)
+	}
+	// nodes added by migrations are reported at the line of their parent
+	inheritLines(root, root.Line)
+	return original, changes, nil
+}
+
+// inheritLines sets line of nodes without one to line of their parent
+func inheritLines(node *yaml.Node, parentLine int) {
+	if node.Line == 0 {
+		node.Line = parentLine
+	}
+	for _, child := range node.Content {
+		inheritLines(child, node.Line)
+	}
+}
+
+// lineMap maps lines of migrated content to lines of the file it was migrated from,
+// nil for content used as read
+type lineMap map[int]int
+
+// mapLines returns lines of root, as read from the file, by lines of the same nodes in migrated
+func mapLines(root *yaml.Node, migrated *yaml.Node, lines lineMap) {
+	if _, known := lines[migrated.Line]; !known {
+		lines[migrated.Line] = root.Line
+	}
+	if len(root.Content) != len(migrated.Content) {
+		return
+	}
+	for i := range root.Content {
+		mapLines(root.Content[i], migrated.Content[i], lines)
+	}
+}
+
+// original returns line of the file a line of migrated content came from
+func (lines lineMap) original(line int) int {
+	if lines == nil || line == 0 {
+		return line
+	}
+	// lines without nodes, e.g. comments, belong to the node above
+	for ; line > 0; line-- {
+		if original, ok := lines[line]; ok {
+			return original
+		}
+	}
+	return 0
+}
+
+// problems returns problems found in migrated content, at lines of the file
+func (lines lineMap) problems(problems []configProblem) []configProblem {
+	for i := range problems {
+		problems[i].line = lines.original(problems[i].line)
+	}
+	return problems
+}
+
+// restore sets lines of nodes parsed from migrated content to lines of the file
+func (lines lineMap) restore(node *yaml.Node) {
+	if lines == nil || node == nil {
+		return
+	}
+	node.Line = lines.original(node.Line)
+	for _, child := range node.Content {
+		lines.restore(child)
+	}
+}
+
+// migrateContent upgrades file content in memory, logging applied changes. Line map of
+// migrated content is returned for reporting problems at lines of the file.
+func migrateContent(file string, content []byte, layout configLayout) ([]byte, lineMap, []configProblem) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, nil, yamlErrorProblems(file, err)
+	}
+
+	_, changes, err := migrateDocument(&root, layout)
+	if err != nil {
+		return nil, nil, []configProblem{{file, nodeLine(findNode(&root, "apiVersion")), err.Error()}}
+	}
+	if len(changes) == 0 {
+		// current file, or older version without layout changes applying to the file
+		return content, nil, nil
+	}
+
+	for _, change := range changes {
+		log.Warn("Configuration file '", file, "' migrated in memory, ", change)
+	}
+	log.Warn("Run 'casavue config migrate' to rewrite '", file, "' in ", layout.current)
+
+	migrated, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, nil, []configProblem{{file: file, message: err.Error()}}
+	}
+	var migratedRoot yaml.Node
+	if err := yaml.Unmarshal(migrated, &migratedRoot); err != nil {
+		return nil, nil, []configProblem{{file: file, message: err.Error()}}
+	}
+	lines := lineMap{}
+	mapLines(&root, &migratedRoot, lines)
+	return migrated, lines, nil
+}
+
+// indentOf returns indentation width of the first indented line, 2 when there is none
+func indentOf(content []byte) int {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent > 0 && len(trimmed) > 0 && trimmed[0] != '#' && trimmed[0] != '-' {
+			return indent
+		}
+	}
+	return 2
+}
+
+// migrateFile rewrites file in the current version, keeping comments and indentation.
+// Files needing no changes are left untouched.
+func migrateFile(file string, layout configLayout, dryRun bool) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return err
+	}
+
+	original, changes, err := migrateDocument(&root, layout)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Printf("%s: %s, no changes needed\n", file, original)
+		return nil
+	}
+	if original == layout.current {
+		fmt.Printf("%s: %s, rewriting deprecated keys\n", file, original)
+	} else {
+		fmt.Printf("%s: %s -> %s\n", file, original, layout.current)
+	}
+	for _, change := range changes {
+		fmt.Println("  ", change)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indentOf(content))
+	if err := encoder.Encode(&root); err != nil {
+		return err
+	}
+	encoder.Close()
+
+	if dryRun {
+		fmt.Print(buf.String())
+		return nil
+	}
+
+	// keep the original file next to the migrated one
+	if err := os.WriteFile(file+".bak", content, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), 0644)
+}
+
+// runConfigCommand implements 'casavue config migrate', returning process exit code
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "migrate" {
+		fmt.Fprintln(os.Stderr, "Usage: casavue config migrate [--config-file path] [--items-file path] [--dry-run]")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config migrate", flag.ExitOnError)
+	mainPath := flags.String("config-file", configFilePath, "Path to main configuration file.")
+	itemsPath := flags.String("items-file", itemsFilePath, "Path to static items file.")
+	dryRun := flags.Bool("dry-run", false, "Print migrated files instead of writing them.")
+	flags.Parse(args[1:])
+
+	exitCode := 0
+	if err := migrateFile(*mainPath, mainConfigLayout, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *mainPath, err)
+		exitCode = 1
+	}
+	if err := migrateFile(*itemsPath, staticItemsLayout, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *itemsPath, err)
+		exitCode = 1
+	}
+	return exitCode
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const legacyMainConfig = `# main configuration
+content_filters:
+    # only annotated items
+    namespace:
+        mode: exclude
+        pattern: "^kube-"
+    item:
+        mode: ingressAnnotation
+logging:
+    level: info
+`
+
+func TestMigrateLegacyFilters(t *testing.T) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(legacyMainConfig), &root); err != nil {
+		t.Fatal(err)
+	}
+	original, changes, err := migrateDocument(&root, mainConfigLayout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original != "casavue.app/v1" || len(changes) != 1 {
+		t.Fatalf("expected one change of unversioned file as casavue.app/v1, got %s %v", original, changes)
+	}
+	if version := findNode(&root, "apiVersion"); version == nil || version.Value != mainConfigLayout.current {
+		t.Errorf("expected apiVersion %s, got %v", mainConfigLayout.current, version)
+	}
+	if findNode(&root, "content_filters", "namespace") != nil || findNode(&root, "content_filters", "item") != nil {
+		t.Error("legacy filters left in migrated document")
+	}
+
+	var migrated ContentFilters
+	if err := findNode(&root, "content_filters").Decode(&migrated); err != nil {
+		t.Fatal(err)
+	}
+	expected := translateLegacyFilters(ContentFilters{
+		Namespace: Filter{Mode: "exclude", Pattern: "^kube-"},
+		Item:      Filter{Mode: "ingressAnnotation"},
+	})
+	if !reflect.DeepEqual(migrated.Rules, expected) {
+		t.Errorf("expected rules %+v, got %+v", expected, migrated.Rules)
+	}
+}
+
+func TestMigratedConfigKeepsFiltering(t *testing.T) {
+	legacy, _, problems := decodeMainConfig("main.yaml", []byte(legacyMainConfig))
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	if len(legacy.Content_filters.Rules) == 0 || legacy.Content_filters.Namespace.Mode != "" {
+		t.Errorf("expected legacy filters migrated to rules on load, got %+v", legacy.Content_filters)
+	}
+}
+
+func TestMigratedConfigProblemLines(t *testing.T) {
+	content := legacyMainConfig + `customization:
+    name: CasaVue
+    unknown_key: true
+logging_typo:
+    level: info
+`
+	// lines of the file as written, not of the migrated document
+	expected := map[string]int{"unknown_key": 13, "logging_typo": 14}
+	_, _, problems := decodeMainConfig("main.yaml", []byte(content))
+	for key, line := range expected {
+		found := false
+		for _, problem := range problems {
+			if strings.Contains(problem.message, key) {
+				found = true
+				if problem.line != line {
+					t.Errorf("%s reported at line %d, expected %d", key, problem.line, line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expected problem for %s, got %v", key, problems)
+		}
+	}
+
+	invalid := strings.Replace(legacyMainConfig, "level: info", "level: loud", 1)
+	problems = validateMainConfig("main.yaml", []byte(invalid))
+	if len(problems) != 1 || problems[0].line != 10 {
+		t.Errorf("expected invalid log level at line 10, got %v", problems)
+	}
+	invalid = strings.Replace(legacyMainConfig, `pattern: "^kube-"`, `pattern: "(kube"`, 1)
+	problems = validateMainConfig("main.yaml", []byte(invalid))
+	if len(problems) != 1 || problems[0].line != 4 {
+		t.Errorf("expected invalid migrated rule at line 4 of replaced filter, got %v", problems)
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// current files, and older ones without changes to apply, are left untouched
+	for name, layout := range map[string]configLayout{"main.yaml": mainConfigLayout, "items.yaml": staticItemsLayout} {
+		content, err := os.ReadFile(filepath.Join("config", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := write(name, string(content))
+		if err := migrateFile(path, layout, false); err != nil {
+			t.Fatal(err)
+		}
+		if after, _ := os.ReadFile(path); !bytes.Equal(after, content) {
+			t.Errorf("%s: current file changed by migrate", name)
+		}
+		if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+			t.Errorf("%s: backup written for current file", name)
+		}
+	}
+
+	path := write("legacy.yaml", legacyMainConfig)
+	if err := migrateFile(path, mainConfigLayout, false); err != nil {
+		t.Fatal(err)
+	}
+	if backup, _ := os.ReadFile(path + ".bak"); string(backup) != legacyMainConfig {
+		t.Error("original file not kept as backup")
+	}
+	migrated, _ := os.ReadFile(path)
+	for _, expected := range []string{"# main configuration\napiVersion: casavue.app/v1", "# only annotated items", "\n    rules:\n", "\nlogging:\n    level: info"} {
+		if !strings.Contains(string(migrated), expected) {
+			t.Errorf("expected %q in migrated file:\n%s", expected, migrated)
+		}
+	}
+
+	if err := migrateFile(write("future.yaml", "apiVersion: casavue.app/v9\n"), mainConfigLayout, false); err == nil {
+		t.Error("expected unsupported apiVersion error")
+	}
+}
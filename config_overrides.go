@@ -0,0 +1,155 @@
+// overriding configuration values with environment variables and command-line flags
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "CASAVUE_"
+
+// configField is a single settable value of the Config structure
+type configField struct {
+	// dotted YAML path, also used as the flag name
+	path  string
+	index []int
+	kind  reflect.Kind
+}
+
+// envName returns the environment variable overriding the field
+func (f configField) envName() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(f.path, ".", "_"))
+}
+
+// yamlFieldName mirrors yaml.v3 naming: tag name or lowercased field name
+func yamlFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+func collectConfigFields(t reflect.Type, prefix string, index []int) []configField {
+	var result []configField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("yaml") == "-" || field.Tag.Get("override") == "-" {
+			continue
+		}
+		path := yamlFieldName(field)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if field.Type.Kind() == reflect.Struct {
+			result = append(result, collectConfigFields(field.Type, path, fieldIndex)...)
+			continue
+		}
+		result = append(result, configField{path, fieldIndex, field.Type.Kind()})
+	}
+	return result
+}
+
+// configFields lists all values which can be overridden
+func configFields() []configField {
+	return collectConfigFields(reflect.TypeOf(Config{}), "", nil)
+}
+
+// setConfigField parses raw value into the field, non-string values are parsed as YAML
+func setConfigField(cfg *Config, field configField, raw string) error {
+	value := reflect.ValueOf(cfg).Elem().FieldByIndex(field.index)
+	if field.kind == reflect.String {
+		value.SetString(raw)
+		return nil
+	}
+
+	parsed := reflect.New(value.Type())
+	if err := yaml.Unmarshal([]byte(raw), parsed.Interface()); err != nil {
+		return fmt.Errorf("invalid value for '%s': %w", field.path, err)
+	}
+	value.Set(parsed.Elem())
+	return nil
+}
+
+// values passed with command-line flags, by config field path
+var configFlagValues = map[string]string{}
+
+// configFlag collects a config override passed on command line
+type configFlag struct {
+	field configField
+}
+
+func (f configFlag) String() string {
+	return configFlagValues[f.field.path]
+}
+
+func (f configFlag) Set(value string) error {
+	configFlagValues[f.field.path] = value
+	return nil
+}
+
+func (f configFlag) IsBoolFlag() bool {
+	return f.field.kind == reflect.Bool
+}
+
+func registerConfigFlags(flags *flag.FlagSet) {
+	for _, field := range configFields() {
+		flags.Var(configFlag{field}, field.path, fmt.Sprintf("Overrides '%s' config value (env: %s).", field.path, field.envName()))
+	}
+}
+
+// applyConfigOverrides applies environment variables, then command-line flags on top of YAML values
+func applyConfigOverrides(cfg *Config) error {
+	fields := configFields()
+
+	for _, field := range fields {
+		if value, ok := os.LookupEnv(field.envName()); ok {
+			log.Debug("Config value '", field.path, "' set from environment variable ", field.envName())
+			if err := setConfigField(cfg, field, value); err != nil {
+				return fmt.Errorf("%s: %w", field.envName(), err)
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if value, ok := configFlagValues[field.path]; ok {
+			log.Debug("Config value '", field.path, "' set from command-line flag")
+			if err := setConfigField(cfg, field, value); err != nil {
+				return fmt.Errorf("--%s: %w", field.path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// envOrDefault returns value of environment variable, or fallback when unset
+func envOrDefault(name string, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
+}
+
+// printEffectiveConfig writes merged configuration to stdout,
+// with secret references left unresolved
+func printEffectiveConfig() {
+	cfg, err := readMergedConfig(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		log.Fatal("Error marshalling effective config: ", err)
+	}
+	fmt.Printf("# config file: %s\n# items file: %s\n# static files: %s\n%s", configFilePath, itemsFilePath, staticFilesPath, out)
+}
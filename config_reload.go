@@ -0,0 +1,91 @@
+// applying main.yaml changes at runtime, without restart
+
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// polling (instead of inotify) also catches ConfigMap symlink swaps
+const configWatchInterval = 5 * time.Second
+
+// functions re-evaluating filters against already known item sources
+var sourceResyncs struct {
+	sync.Mutex
+	funcs []func()
+}
+
+func registerSourceResync(resync func()) {
+	sourceResyncs.Lock()
+	sourceResyncs.funcs = append(sourceResyncs.funcs, resync)
+	sourceResyncs.Unlock()
+}
+
+func resyncSources() {
+	sourceResyncs.Lock()
+	funcs := append([]func(){}, sourceResyncs.funcs...)
+	sourceResyncs.Unlock()
+
+	for _, resync := range funcs {
+		resync()
+	}
+}
+
+// resyncStaticItems re-applies content filters and overrides to items.yaml entries,
+// crawling only entries that changed
+func resyncStaticItems() {
+	for _, name := range readStaticItems() {
+		go crawlItem(name)
+	}
+}
+
+func reloadConfig() error {
+	newConfig, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	applyConfig(newConfig)
+
+	// customization.go
+	writeFrontendConfig()
+	updateFrontendFiles()
+
+	resyncSources()
+	return nil
+}
+
+func readConfigChecksum() string {
+	content, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return ""
+	}
+	return strToSha256(string(content))
+}
+
+func watchConfigFile() {
+	registerSourceResync(resyncStaticItems)
+
+	checksum := readConfigChecksum()
+	for {
+		time.Sleep(configWatchInterval)
+
+		current := readConfigChecksum()
+		if current == checksum {
+			continue
+		}
+		checksum = current
+
+		log.Info("Configuration file '", configFilePath, "' changed, reloading.")
+		if err := reloadConfig(); err != nil {
+			log.Error("Error reloading configuration, keeping previous one: ", err)
+			readiness.set(readyConfig, true, "reload failed, previous configuration in use")
+			continue
+		}
+		log.Info("Configuration reloaded.")
+		readiness.set(readyConfig, true, "reloaded from "+configFilePath)
+	}
+}
@@ -0,0 +1,413 @@
+// validation of configuration files, reporting problems with their location
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/PerformLine/go-stockutil/colorutil"
+	"gopkg.in/yaml.v3"
+)
+
+// configProblem describes a single issue found in a configuration file
+type configProblem struct {
+	file    string
+	line    int
+	message string
+}
+
+func (p configProblem) String() string {
+	if p.line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.file, p.line, p.message)
+	}
+	return fmt.Sprintf("%s: %s", p.file, p.message)
+}
+
+// problemsError joins problems into a single error, nil when there are none
+func problemsError(problems []configProblem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, problem := range problems {
+		lines = append(lines, problem.String())
+	}
+	return errors.New("invalid configuration:\n" + strings.Join(lines, "\n"))
+}
+
+var yamlLineRegex = regexp.MustCompile(`line (\d+): (.*)$`)
+
+var hexColorRegex = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// yamlErrorProblems converts yaml.v3 errors into problems with line numbers
+func yamlErrorProblems(file string, err error) []configProblem {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	var problems []configProblem
+	for _, message := range messages {
+		problem := configProblem{file: file, message: message}
+		if match := yamlLineRegex.FindStringSubmatch(message); match != nil {
+			problem.line, _ = strconv.Atoi(match[1])
+			problem.message = match[2]
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// findNode returns the value node under the given mapping keys
+func findNode(node *yaml.Node, path ...string) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+func nodeLine(node *yaml.Node) int {
+	if node == nil {
+		return 0
+	}
+	return node.Line
+}
+
+// decodeStrict unpacks content into out, rejecting unknown keys. Nodes and problems of
+// migrated content carry lines of the file, by lines.
+func decodeStrict(file string, content []byte, lines lineMap, out interface{}) (*yaml.Node, []configProblem) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, lines.problems(yamlErrorProblems(file, err))
+	}
+	// config_migration.go
+	lines.restore(&root)
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return &root, lines.problems(yamlErrorProblems(file, err))
+	}
+	return &root, nil
+}
+
+func validateFilter(file string, root *yaml.Node, filter Filter, section string) []configProblem {
+	var problems []configProblem
+
+	switch filter.Mode {
+	case "", "include", "exclude", "ingressAnnotation":
+	default:
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "content_filters", section, "mode")),
+			fmt.Sprintf("invalid filter mode %q, allowed values: include, exclude, ingressAnnotation", filter.Mode)})
+	}
+
+	if _, err := regexp.Compile(filter.Pattern); err != nil {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "content_filters", section, "pattern")),
+			fmt.Sprintf("invalid filter pattern: %s", err)})
+	}
+	return problems
+}
+
+// decodeMainConfig unpacks main.yaml content layered on default values
+func decodeMainConfig(file string, content []byte) (Config, *yaml.Node, []configProblem) {
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(default_config), &cfg); err != nil {
+		return cfg, nil, []configProblem{{file: "default config", message: err.Error()}}
+	}
+
+	// config_migration.go
+	content, lines, problems := migrateContent(file, content, mainConfigLayout)
+	if problems != nil {
+		return cfg, nil, problems
+	}
+
+	root, problems := decodeStrict(file, content, lines, &cfg)
+	return cfg, root, problems
+}
+
+// validateMainConfig checks main.yaml content layered on default values
+func validateMainConfig(file string, content []byte) []configProblem {
+	cfg, root, problems := decodeMainConfig(file, content)
+	if root == nil {
+		return problems
+	}
+
+	// secrets.go
+	problems = append(problems, resolveConfigSecrets(file, &cfg)...)
+	return append(problems, validateConfigValues(file, root, cfg)...)
+}
+
+// validateConfigValues checks semantics of cfg, using root for locating values
+func validateConfigValues(file string, root *yaml.Node, cfg Config) []configProblem {
+	var problems []configProblem
+
+	if _, err := parseLogLevel(cfg.Logging.Level); err != nil {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "logging", "level")),
+			fmt.Sprintf("%s, allowed values: debug, info, warn, error", err)})
+	}
+	if format := cfg.Logging.Format; format != "" && format != "text" && format != "json" {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "logging", "format")),
+			fmt.Sprintf("unknown log format %q, allowed values: text, json", format)})
+	}
+	if sampling := cfg.Logging.Access.Sampling; sampling < 0 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "logging", "access", "sampling")),
+			fmt.Sprintf("sampling %d has to be 0 or more", sampling)})
+	}
+	for _, proxy := range cfg.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		if cidrErr != nil && net.ParseIP(proxy) == nil {
+			problems = append(problems, configProblem{file, nodeLine(findNode(root, "server", "trustedProxies")),
+				fmt.Sprintf("invalid trusted proxy %q: expected IP address or CIDR range", proxy)})
+		}
+	}
+
+	if interval := cfg.StatusChecks.Interval; interval < 1 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "statusChecks", "interval")),
+			fmt.Sprintf("status check interval %d has to be 1 or more", interval)})
+	}
+	if jitter := cfg.StatusChecks.Jitter; jitter < 0 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "statusChecks", "jitter")),
+			fmt.Sprintf("status check jitter %d has to be 0 or more", jitter)})
+	}
+	if concurrency := cfg.StatusChecks.Concurrency; concurrency < 1 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "statusChecks", "concurrency")),
+			fmt.Sprintf("status check concurrency %d has to be 1 or more", concurrency)})
+	}
+	for _, message := range validateCheck(cfg.StatusChecks.Defaults) {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "statusChecks", "defaults")), message})
+	}
+	if samples := cfg.StatusChecks.History.Samples; samples < 0 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "statusChecks", "history", "samples")),
+			fmt.Sprintf("history samples %d has to be 0 or more", samples)})
+	}
+	for _, network := range cfg.StatusChecks.DeniedNetworks {
+		_, _, cidrErr := net.ParseCIDR(network)
+		if cidrErr != nil && net.ParseIP(network) == nil {
+			problems = append(problems, configProblem{file, nodeLine(findNode(root, "statusChecks", "deniedNetworks")),
+				fmt.Sprintf("invalid denied network %q: expected IP address or CIDR range", network)})
+		}
+	}
+
+	problems = append(problems, validateFilter(file, root, cfg.Content_filters.Namespace, "namespace")...)
+	problems = append(problems, validateFilter(file, root, cfg.Content_filters.Item, "item")...)
+
+	rulesNode := findNode(root, "content_filters", "rules")
+	for idx, rule := range cfg.Content_filters.Rules {
+		line := nodeLine(rulesNode)
+		if rulesNode != nil && idx < len(rulesNode.Content) {
+			line = rulesNode.Content[idx].Line
+		}
+		for _, message := range validateFilterRule(rule) {
+			problems = append(problems, configProblem{file, line, fmt.Sprintf("content filter rule #%d: %s", idx+1, message)})
+		}
+	}
+	overridesNode := findNode(root, "overrides", "rules")
+	for idx, override := range cfg.Overrides.Rules {
+		line := nodeLine(overridesNode)
+		if overridesNode != nil && idx < len(overridesNode.Content) {
+			line = overridesNode.Content[idx].Line
+		}
+		for _, message := range validateOverride(override) {
+			problems = append(problems, configProblem{file, line, fmt.Sprintf("override rule #%d: %s", idx+1, message)})
+		}
+	}
+	switch cfg.Overrides.Precedence {
+	case "", "overrides", "annotations":
+	default:
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "overrides", "precedence")),
+			fmt.Sprintf("invalid overrides precedence %q, allowed values: overrides, annotations", cfg.Overrides.Precedence)})
+	}
+
+	legacy := cfg.Content_filters.Namespace.Mode != "" || cfg.Content_filters.Item.Mode != ""
+	if legacy && len(cfg.Content_filters.Rules) > 0 {
+		problems = append(problems, configProblem{file, nodeLine(rulesNode),
+			"content_filters: 'rules' can't be combined with legacy 'namespace' and 'item' filters"})
+	}
+
+	// theme color is parsed by getContrastColor when generating PWA icons,
+	// and updateIndexHTML only recognizes hexadecimal notation
+	theme := cfg.Customization.Colors.Theme
+	if _, err := colorutil.Parse(theme); err != nil {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "customization", "colors", "theme")),
+			fmt.Sprintf("invalid theme color %q: %s", theme, err)})
+	} else if !hexColorRegex.MatchString(theme) {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "customization", "colors", "theme")),
+			fmt.Sprintf("invalid theme color %q: expected hexadecimal format, e.g. \"#aabbcc\"", theme)})
+	}
+	if s := cfg.Customization.Colors.Items.Saturation; s < 0 || s > 100 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "customization", "colors", "items", "saturation")),
+			fmt.Sprintf("saturation %d out of range 0 <-> 100", s)})
+	}
+	if l := cfg.Customization.Colors.Items.Lightness; l < -1 || l > 100 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "customization", "colors", "items", "lightness")),
+			fmt.Sprintf("lightness %d out of range -1 <-> 100", l)})
+	}
+	if p := cfg.Server.Port; p < 1 || p > 65535 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "server", "port")),
+			fmt.Sprintf("port %d out of range 1 <-> 65535", p)})
+	}
+	if listen := cfg.Server.Listen; listen != "" && strings.Contains(listen, ":") && net.ParseIP(listen) == nil {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "server", "listen")),
+			fmt.Sprintf("invalid listen address %q: expected IP address or host name, without port", listen)})
+	}
+	if bp := cfg.Server.BasePath; bp != "" && (!strings.HasPrefix(bp, "/") || strings.ContainsAny(bp, "?#")) {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "server", "basePath")),
+			fmt.Sprintf("invalid base path %q: expected path starting with \"/\"", bp)})
+	}
+	problems = append(problems, validateTLS(file, root, cfg.Server)...)
+	if maxAge := cfg.Server.Headers.HSTSMaxAge; maxAge < 0 {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "server", "headers", "hstsMaxAge")),
+			fmt.Sprintf("hstsMaxAge %d has to be 0 or more", maxAge)})
+	}
+	if policy := cfg.Server.Headers.ReferrerPolicy; policy != "" && !slices.Contains(referrerPolicies, policy) {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "server", "headers", "referrerPolicy")),
+			fmt.Sprintf("unknown referrer policy %q, allowed values: %s", policy, strings.Join(referrerPolicies, ", "))})
+	}
+	for _, source := range cfg.Server.Headers.FrameAncestors {
+		if source == "" || strings.ContainsAny(source, " ;,") {
+			problems = append(problems, configProblem{file, nodeLine(findNode(root, "server", "headers", "frameAncestors")),
+				fmt.Sprintf("invalid frame ancestor %q: expected a single CSP source, e.g. 'self'", source)})
+		}
+	}
+
+	return problems
+}
+
+func validateURL(value string) error {
+	u, err := url.ParseRequestURI(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+	return nil
+}
+
+// decodeStaticItems unpacks items.yaml content, upgraded to the current version
+func decodeStaticItems(file string, content []byte) (StaticItems, *yaml.Node, []configProblem) {
+	var items StaticItems
+
+	// config_migration.go
+	content, lines, problems := migrateContent(file, content, staticItemsLayout)
+	if problems != nil {
+		return items, nil, problems
+	}
+
+	root, problems := decodeStrict(file, content, lines, &items)
+	if root == nil {
+		return items, root, problems
+	}
+
+	// secrets.go
+	problems = append(problems, resolveConfigSecrets(file, &items)...)
+	return items, root, problems
+}
+
+func validateStaticItems(file string, content []byte) []configProblem {
+	items, root, problems := decodeStaticItems(file, content)
+	if root == nil {
+		return problems
+	}
+
+	itemsNode := findNode(root, "items")
+	names := map[string]bool{}
+	for idx, item := range items.Items {
+		line := 0
+		if itemsNode != nil && idx < len(itemsNode.Content) {
+			line = itemsNode.Content[idx].Line
+		}
+
+		if item.Name == "" {
+			problems = append(problems, configProblem{file, line, "item is missing required field 'name'"})
+		} else if names[item.Name] {
+			problems = append(problems, configProblem{file, line, fmt.Sprintf("duplicate item name %q", item.Name)})
+		}
+		names[item.Name] = true
+
+		if item.Namespace == "" {
+			problems = append(problems, configProblem{file, line, fmt.Sprintf("item %q is missing required field 'namespace'", item.Name)})
+		}
+		if err := validateURL(item.URL); err != nil {
+			problems = append(problems, configProblem{file, line, fmt.Sprintf("item %q has invalid url %q: %s", item.Name, item.URL, err)})
+		}
+		if item.Icon != "" {
+			if _, err := url.Parse(item.Icon); err != nil {
+				problems = append(problems, configProblem{file, line, fmt.Sprintf("item %q has invalid icon %q: %s", item.Name, item.Icon, err)})
+			}
+		}
+		if item.Check != nil {
+			// checks.go
+			for _, message := range validateCheck(*item.Check) {
+				problems = append(problems, configProblem{file, line, fmt.Sprintf("item %q: %s", item.Name, message)})
+			}
+		}
+	}
+	return problems
+}
+
+// validateConfigFiles checks both configuration files, skipping missing ones
+func validateConfigFiles(mainPath, itemsPath string) []configProblem {
+	var problems []configProblem
+
+	if content, err := os.ReadFile(mainPath); err == nil {
+		problems = append(problems, validateMainConfig(mainPath, content)...)
+	} else if !os.IsNotExist(err) {
+		problems = append(problems, configProblem{file: mainPath, message: err.Error()})
+	}
+
+	if content, err := os.ReadFile(itemsPath); err == nil {
+		problems = append(problems, validateStaticItems(itemsPath, content)...)
+	} else if !os.IsNotExist(err) {
+		problems = append(problems, configProblem{file: itemsPath, message: err.Error()})
+	}
+	return problems
+}
+
+// runValidateCommand implements 'casavue validate', returning process exit code
+func runValidateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	mainPath := flags.String("config-file", configFilePath, "Path to main configuration file.")
+	itemsPath := flags.String("items-file", itemsFilePath, "Path to static items file.")
+	flags.Parse(args)
+
+	problems := validateConfigFiles(*mainPath, *itemsPath)
+	for _, problem := range problems {
+		fmt.Println(redactSecrets(problem.String()))
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Found %d problem(s) in configuration.\n", len(problems))
+		return 1
+	}
+	fmt.Println("Configuration is valid.")
+	return 0
+}
@@ -0,0 +1,181 @@
+// ordered content filter rules deciding which items are shown
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// item source kinds, matched by the 'source' field of filter rules
+const (
+	sourceStatic         = "static"
+	sourceIngress        = "ingress"
+	sourceHTTPRoute      = "httproute"
+	sourceKnativeService = "knativeservice"
+)
+
+var sourceKinds = []string{sourceStatic, sourceIngress, sourceHTTPRoute, sourceKnativeService}
+
+// filterSubject holds everything a filter rule can match on
+type filterSubject struct {
+	source      string
+	namespace   string
+	name        string
+	host        string
+	labels      map[string]string
+	annotations map[string]string
+}
+
+func objectFilterSubject(source string, obj metav1.Object, itemURL string) filterSubject {
+	return filterSubject{source, obj.GetNamespace(), obj.GetName(), getHostFromURL(itemURL), obj.GetLabels(), obj.GetAnnotations()}
+}
+
+func staticFilterSubject(item Item) filterSubject {
+	return filterSubject{sourceStatic, item.Namespace, item.Name, getHostFromURL(item.URL), nil, nil}
+}
+
+// matchPattern reports whether str matches pattern, an empty pattern matches everything
+func matchPattern(pattern string, str string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := regexp.MatchString(pattern, str)
+	if err != nil {
+		// patterns are checked by validateConfigValues, so this is not expected
+		log.Error("Parsing regular expression error: ", err)
+		return false
+	}
+	return matched
+}
+
+// matchMap requires every key to be present, with value matching its pattern
+func matchMap(patterns map[string]string, values map[string]string) bool {
+	for key, pattern := range patterns {
+		value, ok := values[key]
+		if !ok || !matchPattern(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule FilterRule) matches(subject filterSubject) bool {
+	if len(rule.Source) > 0 {
+		found := false
+		for _, source := range rule.Source {
+			if source == subject.source {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return matchPattern(rule.Namespace, subject.namespace) &&
+		matchPattern(rule.Name, subject.name) &&
+		matchPattern(rule.Host, subject.host) &&
+		matchMap(rule.Labels, subject.labels) &&
+		matchMap(rule.Annotations, subject.annotations)
+}
+
+// translateLegacyFilters converts single namespace and item filters into ordered rules
+func translateLegacyFilters(filters ContentFilters) []FilterRule {
+	var rules []FilterRule
+	include := FilterRule{Action: "include"}
+	hasInclude := false
+
+	switch filters.Namespace.Mode {
+	case "exclude":
+		rules = append(rules, FilterRule{Namespace: filters.Namespace.Pattern, Action: "exclude"})
+	case "include":
+		include.Namespace = filters.Namespace.Pattern
+		hasInclude = true
+	}
+
+	switch filters.Item.Mode {
+	case "exclude":
+		rules = append(rules, FilterRule{Name: filters.Item.Pattern, Action: "exclude"})
+	case "include":
+		include.Name = filters.Item.Pattern
+		hasInclude = true
+	case "ingressAnnotation":
+		// static items are always shown, other sources need the enable annotation
+		static := include
+		static.Source = []string{sourceStatic}
+		annotated := include
+		annotated.Annotations = map[string]string{"casavue.app/enable": ""}
+		return append(rules, static, annotated, FilterRule{Action: "exclude"})
+	}
+
+	if hasInclude {
+		rules = append(rules, include, FilterRule{Action: "exclude"})
+	}
+	return rules
+}
+
+// filterRules returns configured rules, or the translation of legacy filters
+func filterRules(filters ContentFilters) []FilterRule {
+	if len(filters.Rules) > 0 {
+		return filters.Rules
+	}
+	return translateLegacyFilters(filters)
+}
+
+// isIncludedByFilters applies the first matching rule, items matching no rule are included
+func isIncludedByFilters(subject filterSubject) bool {
+	for idx, rule := range filterRules(config.get().Content_filters) {
+		if !rule.matches(subject) {
+			continue
+		}
+		if rule.Action == "exclude" {
+			log.Debug("Skipping ", subject.source, " '", subject.namespace, "/", subject.name, "' due to content filter rule #", idx+1)
+			return false
+		}
+		return true
+	}
+	return true
+}
+
+func validateFilterRule(rule FilterRule) []string {
+	var problems []string
+
+	if rule.Action != "include" && rule.Action != "exclude" {
+		problems = append(problems, fmt.Sprintf("invalid action %q, allowed values: include, exclude", rule.Action))
+	}
+
+	patterns := map[string]string{"namespace": rule.Namespace, "name": rule.Name, "host": rule.Host}
+	for key, pattern := range rule.Labels {
+		patterns["labels."+key] = pattern
+	}
+	for key, pattern := range rule.Annotations {
+		patterns["annotations."+key] = pattern
+	}
+	fields := make([]string, 0, len(patterns))
+	for field := range patterns {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if _, err := regexp.Compile(patterns[field]); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid %s pattern: %s", field, err))
+		}
+	}
+
+	for _, source := range rule.Source {
+		valid := false
+		for _, kind := range sourceKinds {
+			if source == kind {
+				valid = true
+			}
+		}
+		if !valid {
+			problems = append(problems, fmt.Sprintf("invalid source %q, allowed values: %v", source, sourceKinds))
+		}
+	}
+	return problems
+}
@@ -37,15 +37,25 @@ func updateFrontendFiles() {
 	}
 }
 
-const (
-	pwaIconsFolderPath  = compiledVuePath + "/img/icons"
-	sourceLogoPath      = sourceVuePath + "/assets/logo.svg"
-	sourceSmallLogoPath = sourceVuePath + "/assets/logo_small.svg"
-	manifestJSON        = compiledVuePath + "/manifest.json"
-	indexHtmlPath       = compiledVuePath + "/index.html"
-	faviconPath         = compiledVuePath + "/favicon.ico"
+var (
+	pwaIconsFolderPath  string
+	sourceLogoPath      string
+	sourceSmallLogoPath string
+	manifestJSON        string
+	indexHtmlPath       string
+	faviconPath         string
 )
 
+// initCustomizationPaths derives frontend file locations from staticFilesPath
+func initCustomizationPaths() {
+	pwaIconsFolderPath = compiledVuePath + "/img/icons"
+	sourceLogoPath = sourceVuePath + "/assets/logo.svg"
+	sourceSmallLogoPath = sourceVuePath + "/assets/logo_small.svg"
+	manifestJSON = compiledVuePath + "/manifest.json"
+	indexHtmlPath = compiledVuePath + "/index.html"
+	faviconPath = compiledVuePath + "/favicon.ico"
+}
+
 type Manifest struct {
 	Name            string `json:"name"`
 	ShortName       string `json:"short_name"`
@@ -61,12 +71,21 @@ func updateManifestJSON() {
 		return
 	}
 
+	customization := config.get().Customization
+
 	// JSON object with precedence (overwriting original values)
 	overrideObject := map[string]interface{}{
-		"name":             config.Customization.Name,
-		"short_name":       config.Customization.Name,
-		"theme_color":      config.Customization.Colors.Theme,
-		"background_color": config.Customization.Colors.Theme,
+		"name":             customization.Name,
+		"short_name":       customization.Name,
+		"theme_color":      customization.Colors.Theme,
+		"background_color": customization.Colors.Theme,
+		"start_url":        basePath + "/",
+		"scope":            basePath + "/",
+	}
+	if *staticMode {
+		// static files can be hosted at any path
+		overrideObject["start_url"] = "./"
+		overrideObject["scope"] = "./"
 	}
 
 	// Merge the overrideObject into the originalManifest
@@ -85,12 +104,13 @@ func updateManifestJSON() {
 func updateIndexHTML() {
 	// Read the entire content of the file
 	content := readStringFile(indexHtmlPath)
+	customization := config.get().Customization
 
 	// Perform the replacements
-	regexpReplaceString(&content, `<meta name="msapplication-TileColor" content="`, `#[0-9a-fA-F]+`, config.Customization.Colors.Theme, `">`)
-	regexpReplaceString(&content, `<link rel="mask-icon" href="img/icons/safari-pinned-tab.svg" color="`, `#[0-9a-fA-F]+`, config.Customization.Colors.Theme, `">`)
-	regexpReplaceString(&content, `<meta name="theme-color" content="`, `#[0-9a-fA-F]+`, config.Customization.Colors.Theme, `">`)
-	regexpReplaceString(&content, "<title>", `.*?`, config.Customization.Name, "</title>")
+	regexpReplaceString(&content, `<meta name="msapplication-TileColor" content="`, `#[0-9a-fA-F]+`, customization.Colors.Theme, `">`)
+	regexpReplaceString(&content, `<link rel="mask-icon" href="img/icons/safari-pinned-tab.svg" color="`, `#[0-9a-fA-F]+`, customization.Colors.Theme, `">`)
+	regexpReplaceString(&content, `<meta name="theme-color" content="`, `#[0-9a-fA-F]+`, customization.Colors.Theme, `">`)
+	regexpReplaceString(&content, "<title>", `.*?`, customization.Name, "</title>")
 
 	// Write the modified content back to the same file
 	writeStringFile(indexHtmlPath, content)
@@ -61,3 +61,10 @@ Create the name of the service account to use
     {{ default "default" .Values.serviceAccount.name }}
 {{- end -}}
 {{- end -}}
+
+{{/*
+Port CasaVue listens on, server.port of the main config.
+*/}}
+{{- define "casavue.port" -}}
+{{- dig "server" "port" 8080 .Values.config.main | int -}}
+{{- end -}}
@@ -0,0 +1,225 @@
+// pushing dashboard item changes to browsers with Server-Sent Events
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	itemAdded   = "add"
+	itemUpdated = "update"
+	itemDeleted = "delete"
+
+	// status or code of the item's scheduled check changed
+	itemHealthChanged = "health"
+)
+
+const (
+	// events kept for clients resuming with Last-Event-ID
+	itemEventsHistorySize = 1000
+
+	// events buffered per client, slower clients are disconnected and resume
+	itemEventsBufferSize = 256
+
+	eventsHeartbeatInterval = 15 * time.Second
+)
+
+// event IDs are prefixed with process start time, so IDs from a previous run aren't resumed
+var eventsEpoch = strconv.FormatInt(time.Now().Unix(), 10)
+
+type itemEvent struct {
+	revision uint64
+	kind     string
+	name     string
+	entry    DashEntry
+	health   ItemHealth
+}
+
+func eventID(revision uint64) string {
+	return eventsEpoch + "." + strconv.FormatUint(revision, 10)
+}
+
+// parseEventID returns revision of an ID issued by this process
+func parseEventID(id string) (uint64, bool) {
+	epoch, revision, found := strings.Cut(id, ".")
+	if !found || epoch != eventsEpoch {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(revision, 10, 64)
+	return value, err == nil
+}
+
+// ItemEventsBroker fans out item changes to connected clients
+type ItemEventsBroker struct {
+	sync.Mutex
+	history     []itemEvent
+	subscribers map[chan itemEvent]bool
+}
+
+var itemEvents = ItemEventsBroker{subscribers: make(map[chan itemEvent]bool)}
+
+// publish is called by DashboardItemsStore with its lock held, so events are ordered
+func (b *ItemEventsBroker) publish(event itemEvent) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.history = append(b.history, event)
+	if len(b.history) > itemEventsHistorySize {
+		b.history = b.history[len(b.history)-itemEventsHistorySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Debug("Events client too slow, disconnecting")
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a client, returning either events after since (when resuming
+// is possible) or a snapshot of all items with their health, and the revision they lead to
+func (b *ItemEventsBroker) subscribe(since uint64, resume bool) (map[string]DashEntry, map[string]ItemHealth, []itemEvent, uint64, chan itemEvent) {
+	// no item changes are published while the store is read locked
+	dashboardItems.RLock()
+	defer dashboardItems.RUnlock()
+	b.Lock()
+	defer b.Unlock()
+
+	ch := make(chan itemEvent, itemEventsBufferSize)
+	b.subscribers[ch] = true
+	revision := dashboardItems.revision
+
+	if resume && since <= revision {
+		if since == revision {
+			return nil, nil, nil, revision, ch
+		}
+		if len(b.history) > 0 && b.history[0].revision <= since+1 {
+			var replay []itemEvent
+			for _, event := range b.history {
+				if event.revision > since {
+					replay = append(replay, event)
+				}
+			}
+			return nil, nil, replay, revision, ch
+		}
+	}
+
+	snapshot := make(map[string]DashEntry, len(dashboardItems.items))
+	for key, value := range dashboardItems.items {
+		snapshot[key] = value
+	}
+	health := make(map[string]ItemHealth, len(dashboardItems.health))
+	for key, value := range dashboardItems.health {
+		health[key] = value
+	}
+	return snapshot, health, nil, revision, ch
+}
+
+func (b *ItemEventsBroker) unsubscribe(ch chan itemEvent) {
+	b.Lock()
+	defer b.Unlock()
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func writeServerEvent(w http.ResponseWriter, kind string, revision uint64, data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", eventID(revision), kind, jsonData)
+	return err
+}
+
+func writeItemEvent(w http.ResponseWriter, event itemEvent) error {
+	data := map[string]interface{}{"name": event.name}
+	switch event.kind {
+	case itemHealthChanged:
+		data["health"] = event.health
+	case itemAdded, itemUpdated:
+		data["item"] = event.entry
+	}
+	return writeServerEvent(w, event.kind, event.revision, data)
+}
+
+// itemEventsHandler streams a snapshot, then item changes, to a single client
+func itemEventsHandler(w http.ResponseWriter, r *http.Request) {
+	controller := http.NewResponseController(w)
+
+	since, resume := parseEventID(r.Header.Get("Last-Event-ID"))
+	snapshot, health, replay, revision, ch := itemEvents.subscribe(since, resume)
+	defer itemEvents.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// disable response buffering in nginx based proxies
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 5000\n\n")
+	if snapshot != nil {
+		if err := writeServerEvent(w, "snapshot", revision, snapshot); err != nil {
+			return
+		}
+		// health of items already checked follows the snapshot, with the same ID
+		for name, itemHealth := range health {
+			if err := writeItemEvent(w, itemEvent{revision: revision, kind: itemHealthChanged, name: name, health: itemHealth}); err != nil {
+				return
+			}
+		}
+	}
+	for _, event := range replay {
+		if err := writeItemEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		requestLogger(r).Warn("Events stream not supported: ", err)
+		return
+	}
+
+	// statuses.go, with scheduled checks disabled items are checked while clients are connected
+	refreshOutdatedHealth(requestLogger(r))
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				// disconnected as too slow, client resumes with Last-Event-ID
+				return
+			}
+			if event.revision <= revision {
+				continue
+			}
+			if err := writeItemEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			refreshOutdatedHealth(requestLogger(r))
+		case <-r.Context().Done():
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
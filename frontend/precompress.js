@@ -0,0 +1,23 @@
+// writes .gz and .br variants of hashed build assets, served by the Go backend
+// files rewritten at runtime (index.html, manifest.json, ...) are left out
+
+const fs = require('fs');
+const path = require('path');
+const zlib = require('zlib');
+
+const hashedAsset = /\.[0-9a-f]{8,}\.(js|css|svg)$/;
+
+function walk(dir) {
+  for (const entry of fs.readdirSync(dir, { withFileTypes: true })) {
+    const file = path.join(dir, entry.name);
+    if (entry.isDirectory()) {
+      walk(file);
+    } else if (hashedAsset.test(entry.name)) {
+      const content = fs.readFileSync(file);
+      fs.writeFileSync(file + '.gz', zlib.gzipSync(content, { level: 9 }));
+      fs.writeFileSync(file + '.br', zlib.brotliCompressSync(content));
+    }
+  }
+}
+
+walk(path.join(__dirname, 'dist'));
@@ -6,6 +6,7 @@ toolchain go1.24.5
 
 require (
 	github.com/PerformLine/go-stockutil v1.9.5
+	github.com/andybalholm/brotli v1.2.0
 	github.com/aofei/cameron v1.2.1
 	github.com/biessek/golang-ico v0.0.0-20180326222316-d348d9ea4670
 	github.com/disintegration/imaging v1.6.2
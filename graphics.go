@@ -70,7 +70,7 @@ func convertSvgToFavicons(svgPath string) error {
 	svgContent, _ := openSvgToString(svgPath)
 
 	// Replace 'fill="#e0f3ff"' with the value from config.Customization.Colors.Contrast
-	themeColor := config.Customization.Colors.Theme
+	themeColor := config.get().Customization.Colors.Theme
 	contrastColor := getContrastColor(themeColor)
 	svgContent = strings.ReplaceAll(string(svgContent), `fill="#e0f3ff"`, fmt.Sprintf(`fill="%s"`, contrastColor))
 
@@ -107,7 +107,7 @@ func convertSvgToPng(svgPath string, pngPath string, sideLength int) error {
 	svgContent, _ := openSvgToString(svgPath)
 
 	// Replace color occurrences
-	replacedContent := strings.Replace(svgContent, "#e0f3ff", getContrastColor(config.Customization.Colors.Theme), -1)
+	replacedContent := strings.Replace(svgContent, "#e0f3ff", getContrastColor(config.get().Customization.Colors.Theme), -1)
 	img := svgToRaster(replacedContent, sideLength)
 
 	// Resize the image to add padding (15% of side length)
@@ -115,7 +115,7 @@ func convertSvgToPng(svgPath string, pngPath string, sideLength int) error {
 
 	// Set the background color
 	canvas := image.NewRGBA(image.Rect(0, 0, sideLength, sideLength))
-	bgColor := colorutil.MustParse(config.Customization.Colors.Theme)
+	bgColor := colorutil.MustParse(config.get().Customization.Colors.Theme)
 	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{bgColor}, image.Point{}, draw.Src)
 
 	// Center the image on a new square canvas (with the original side length)
@@ -141,7 +141,7 @@ func svgToRaster(svgContent string, sideLength int) image.Image {
 	img := image.NewRGBA(image.Rect(0, 0, sideLength, sideLength))
 
 	// Set the background color
-	bgColor := colorutil.MustParse(config.Customization.Colors.Theme)
+	bgColor := colorutil.MustParse(config.get().Customization.Colors.Theme)
 	draw.Draw(img, img.Bounds(), &image.Uniform{bgColor}, image.Point{}, draw.Src)
 
 	// draw icon
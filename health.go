@@ -0,0 +1,85 @@
+// liveness and readiness endpoints for container probes
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// readiness check names, besides source kinds from content_filters.go
+const (
+	readyConfig      = "config"
+	readyStaticCrawl = "staticCrawl"
+)
+
+type ReadinessCheck struct {
+	Ready   bool   `json:"ready"`
+	Message string `json:"message,omitempty"`
+}
+
+// ReadinessStore holds state of all checks the instance has to pass to be ready
+type ReadinessStore struct {
+	sync.RWMutex
+	checks map[string]ReadinessCheck
+}
+
+var readiness = ReadinessStore{checks: make(map[string]ReadinessCheck)}
+
+// expect registers a check which is not ready yet
+func (s *ReadinessStore) expect(name string, message string) {
+	s.Lock()
+	defer s.Unlock()
+	s.checks[name] = ReadinessCheck{false, message}
+}
+
+func (s *ReadinessStore) set(name string, ready bool, message string) {
+	s.Lock()
+	defer s.Unlock()
+	s.checks[name] = ReadinessCheck{ready, message}
+}
+
+// get returns a copy of checks and whether all of them are ready
+func (s *ReadinessStore) get() (map[string]ReadinessCheck, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	checks := make(map[string]ReadinessCheck, len(s.checks))
+	ready := true
+	for name, check := range s.checks {
+		checks[name] = check
+		ready = ready && check.Ready
+	}
+	return checks, ready
+}
+
+// healthzHandler reports the process is alive and serving requests
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// readyzHandler reports each readiness check, with 503 until all of them pass
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	checks, ready := readiness.get()
+	status := "ready"
+	if !ready {
+		status = "not ready"
+	}
+
+	jsonData, err := json.Marshal(struct {
+		Status string                    `json:"status"`
+		Checks map[string]ReadinessCheck `json:"checks"`
+	}{status, checks})
+	if err != nil {
+		requestLogger(r).Error("Error marshalling JSON: ", err)
+		http.Error(w, "Error marshalling JSON", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(jsonData)
+}
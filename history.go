@@ -0,0 +1,381 @@
+// status check history of items, uptime and latency statistics
+
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// histories of items gone for longer than the longest window are dropped
+	historyRetention = 30 * 24 * time.Hour
+
+	historySaveInterval = time.Minute
+
+	// recent samples returned for sparklines when not set by the samples parameter
+	historyDefaultSamples = 60
+)
+
+// statistics windows of the history endpoint
+var historyWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// historySample is a single check result, exported fields are persisted
+type historySample struct {
+	At        int64
+	Up        bool
+	Code      int16
+	LatencyMs float32
+}
+
+// historyRing keeps the latest samples of an item, oldest are overwritten
+type historyRing struct {
+	Samples []historySample
+	Next    int
+	Full    bool
+}
+
+func (ring *historyRing) add(sample historySample, size int) {
+	if (ring.Full && len(ring.Samples) != size) || len(ring.Samples) > size {
+		// statusChecks.history.samples changed, keep the newest ones
+		ring.resize(size)
+	}
+	if !ring.Full {
+		ring.Samples = append(ring.Samples, sample)
+		ring.Next = len(ring.Samples) % size
+		ring.Full = len(ring.Samples) == size
+		return
+	}
+	ring.Samples[ring.Next] = sample
+	ring.Next = (ring.Next + 1) % size
+}
+
+func (ring *historyRing) resize(size int) {
+	ordered := ring.ordered()
+	if len(ordered) > size {
+		ordered = ordered[len(ordered)-size:]
+	}
+	ring.Samples = ordered
+	ring.Next = len(ordered) % size
+	ring.Full = len(ordered) == size
+}
+
+// newest returns time of the latest sample, ok is false for empty rings
+func (ring *historyRing) newest() (int64, bool) {
+	if len(ring.Samples) == 0 {
+		return 0, false
+	}
+	return ring.Samples[(ring.Next+len(ring.Samples)-1)%len(ring.Samples)].At, true
+}
+
+// ordered returns a copy of samples, oldest first
+func (ring *historyRing) ordered() []historySample {
+	if !ring.Full {
+		return slices.Clone(ring.Samples)
+	}
+	return append(slices.Clone(ring.Samples[ring.Next:]), ring.Samples[:ring.Next]...)
+}
+
+// thread safe store for check histories by item name
+type HistoryStore struct {
+	sync.Mutex
+	rings map[string]*historyRing
+	dirty bool
+}
+
+var statusHistory = HistoryStore{rings: make(map[string]*historyRing)}
+
+func (hs *HistoryStore) record(name string, health ItemHealth) {
+	size := config.get().StatusChecks.History.Samples
+	if size < 1 || health.CheckedAt == nil {
+		return
+	}
+	sample := historySample{
+		At:        health.CheckedAt.Unix(),
+		Up:        health.Status == "up",
+		Code:      int16(health.Code),
+		LatencyMs: float32(health.LatencyMs),
+	}
+
+	hs.Lock()
+	defer hs.Unlock()
+	ring, ok := hs.rings[name]
+	if !ok {
+		ring = &historyRing{}
+		hs.rings[name] = ring
+	}
+	ring.add(sample, size)
+	hs.dirty = true
+}
+
+func (hs *HistoryStore) read(name string) []historySample {
+	hs.Lock()
+	defer hs.Unlock()
+	ring, ok := hs.rings[name]
+	if !ok {
+		return nil
+	}
+	return ring.ordered()
+}
+
+// prune drops histories of items without samples within historyRetention, histories
+// outlive items so a recreated source object keeps the history of its items
+func (hs *HistoryStore) prune(now time.Time) {
+	hs.Lock()
+	defer hs.Unlock()
+	for name, ring := range hs.rings {
+		newest, ok := ring.newest()
+		if !ok || now.Sub(time.Unix(newest, 0)) > historyRetention {
+			delete(hs.rings, name)
+			hs.dirty = true
+		}
+	}
+}
+
+// load reads histories saved by save, replacing current ones
+func (hs *HistoryStore) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rings := map[string]*historyRing{}
+	if err := gob.NewDecoder(file).Decode(&rings); err != nil {
+		return err
+	}
+	hs.Lock()
+	hs.rings = rings
+	hs.Unlock()
+	return nil
+}
+
+// save writes histories to path, through a temporary file so a crash can't leave it half written
+func (hs *HistoryStore) save(path string) error {
+	hs.Lock()
+	defer hs.Unlock()
+	if !hs.dirty {
+		return nil
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if err := gob.NewEncoder(temp).Encode(hs.rings); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+	hs.dirty = false
+	return nil
+}
+
+// runHistoryPersistence loads saved histories, then prunes them periodically and saves
+// them when statusChecks.history.file is set
+func runHistoryPersistence() {
+	path := config.get().StatusChecks.History.File
+	if path != "" {
+		if err := statusHistory.load(path); err != nil && !os.IsNotExist(err) {
+			log.Error("Error loading status history: ", err)
+		}
+	}
+
+	ticker := time.NewTicker(historySaveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		statusHistory.prune(time.Now())
+		saveStatusHistory()
+	}
+}
+
+// saveStatusHistory saves histories to statusChecks.history.file, which may be set or
+// changed on reload
+func saveStatusHistory() {
+	path := config.get().StatusChecks.History.File
+	if path == "" {
+		return
+	}
+	if err := statusHistory.save(path); err != nil {
+		log.Error("Error saving status history: ", err)
+	}
+}
+
+// HistorySample is a check result as returned by the history endpoint
+type HistorySample struct {
+	At        time.Time `json:"at"`
+	Status    string    `json:"status"`
+	Code      int       `json:"code,omitempty"`
+	LatencyMs float64   `json:"latencyMs"`
+}
+
+// LatencyPercentiles of successful checks, in milliseconds
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+type HistoryWindow struct {
+	Window string `json:"window"`
+	Checks int    `json:"checks"`
+	// span of the window kept history covers, complete when it reaches the window start
+	CoveredSeconds int64 `json:"coveredSeconds"`
+	Complete       bool  `json:"complete"`
+	// percentage of successful checks, null without checks in the window
+	Uptime  *float64            `json:"uptime"`
+	Latency *LatencyPercentiles `json:"latency"`
+}
+
+// Outage is a run of failed checks, End is null while it lasts
+type Outage struct {
+	Start           time.Time  `json:"start"`
+	End             *time.Time `json:"end"`
+	DurationSeconds int64      `json:"durationSeconds"`
+}
+
+type ItemHistory struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	Since   *time.Time      `json:"since"`
+	Windows []HistoryWindow `json:"windows"`
+	Outages []Outage        `json:"outages"`
+	Recent  []HistorySample `json:"recent"`
+}
+
+// percentile returns the p-th percentile of sorted values, nearest rank method
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+func historyWindowOf(name string, samples []historySample, from int64, now int64) HistoryWindow {
+	window := HistoryWindow{Window: name}
+	if len(samples) > 0 {
+		window.CoveredSeconds = now - max(from, samples[0].At)
+		window.Complete = samples[0].At <= from
+	}
+	var up int
+	var latencies []float64
+	for _, sample := range samples {
+		if sample.At < from {
+			continue
+		}
+		window.Checks++
+		if sample.Up {
+			up++
+			latencies = append(latencies, float64(sample.LatencyMs))
+		}
+	}
+	if window.Checks > 0 {
+		uptime := math.Round(float64(up)/float64(window.Checks)*100000) / 1000
+		window.Uptime = &uptime
+	}
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		window.Latency = &LatencyPercentiles{
+			percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 95), percentile(latencies, 99),
+		}
+	}
+	return window
+}
+
+// outagesOf returns runs of failed checks, each lasting until the next successful one
+func outagesOf(samples []historySample, now time.Time) []Outage {
+	outages := []Outage{}
+	var current *Outage
+	for _, sample := range samples {
+		at := time.Unix(sample.At, 0).UTC()
+		switch {
+		case !sample.Up && current == nil:
+			current = &Outage{Start: at}
+		case sample.Up && current != nil:
+			current.End = &at
+			current.DurationSeconds = int64(at.Sub(current.Start).Seconds())
+			outages = append(outages, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		current.DurationSeconds = int64(now.Sub(current.Start).Seconds())
+		outages = append(outages, *current)
+	}
+	return outages
+}
+
+func itemHistoryOf(name string, samples []historySample, recent int, now time.Time) ItemHistory {
+	history := ItemHistory{ID: itemID(name), Name: name, Outages: []Outage{}, Recent: []HistorySample{}}
+	if len(samples) > 0 {
+		since := time.Unix(samples[0].At, 0).UTC()
+		history.Since = &since
+	}
+
+	for _, window := range historyWindows {
+		history.Windows = append(history.Windows, historyWindowOf(window.name, samples, now.Add(-window.duration).Unix(), now.Unix()))
+	}
+
+	// outages overlapping the longest window
+	from := now.Add(-historyWindows[len(historyWindows)-1].duration)
+	for _, outage := range outagesOf(samples, now) {
+		if outage.End == nil || outage.End.After(from) {
+			history.Outages = append(history.Outages, outage)
+		}
+	}
+
+	for _, sample := range samples[max(len(samples)-recent, 0):] {
+		status := "down"
+		if sample.Up {
+			status = "up"
+		}
+		history.Recent = append(history.Recent, HistorySample{
+			time.Unix(sample.At, 0).UTC(), status, int(sample.Code), float64(sample.LatencyMs),
+		})
+	}
+	return history
+}
+
+// itemHistoryHandler serves /api/v2/items/{id}/history
+func itemHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	recent := historyDefaultSamples
+	if raw := r.URL.Query().Get("samples"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 || value > apiV2MaxLimit {
+			writeJSONError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid samples %q, expected integer 0 <-> %d", raw, apiV2MaxLimit))
+			return
+		}
+		recent = value
+	}
+
+	id := r.PathValue("id")
+	for _, name := range dashboardItems.getKeys() {
+		if itemID(name) == id {
+			writeJSON(w, r, http.StatusOK, itemHistoryOf(name, statusHistory.read(name), recent, time.Now()))
+			return
+		}
+	}
+	writeJSONError(w, r, http.StatusNotFound, fmt.Sprintf("item %q not found", id))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryWindowCoverage(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var samples []historySample
+	for at := now.Add(-8 * 24 * time.Hour); !at.After(now); at = at.Add(time.Hour) {
+		samples = append(samples, historySample{At: at.Unix(), Up: true, Code: 200, LatencyMs: 10})
+	}
+
+	expected := map[string]struct {
+		covered  time.Duration
+		complete bool
+	}{
+		"24h": {24 * time.Hour, true},
+		"7d":  {7 * 24 * time.Hour, true},
+		"30d": {8 * 24 * time.Hour, false},
+	}
+	for _, window := range itemHistoryOf("grafana", samples, 0, now).Windows {
+		want := expected[window.Window]
+		if window.CoveredSeconds != int64(want.covered.Seconds()) || window.Complete != want.complete {
+			t.Errorf("%s: expected %d seconds covered, complete %v, got %d, %v",
+				window.Window, int64(want.covered.Seconds()), want.complete, window.CoveredSeconds, window.Complete)
+		}
+	}
+}
@@ -4,48 +4,115 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	log "github.com/sirupsen/logrus"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+// URL path prefix all handlers are served under, without trailing slash
+var basePath string
+
+// normalizeBasePath returns path with a leading and without a trailing slash, "" for root
+func normalizeBasePath(path string) string {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
 func initHttpServer() {
 	// Define a handler function to handle HTTP requests
 
-	http.HandleFunc("/api/v1", entriesApiHandler)
+	// handlers are wrapped with instrumentHandler from metrics.go
+	http.HandleFunc(basePath+"/api/v1", instrumentHandler("api", compressHandler(entriesApiHandler)))
+
+	// api_v2.go
+	http.HandleFunc(basePath+apiV2Path+"/items", instrumentHandler("api_v2_items", compressHandler(itemsV2Handler)))
+	http.HandleFunc(basePath+apiV2Path+"/namespaces", instrumentHandler("api_v2_namespaces", compressHandler(namespacesV2Handler)))
+
+	// history.go
+	http.HandleFunc("GET "+basePath+apiV2Path+"/items/{id}/history", instrumentHandler("api_v2_item_history", compressHandler(itemHistoryHandler)))
+
+	// openapi.go
+	http.HandleFunc(basePath+"/api/openapi.json", instrumentHandler("openapi", compressHandler(openAPIHandler)))
+
+	// events.go, long-lived streams are left out of latency metrics
+	http.HandleFunc(basePath+"/api/v1/events", itemEventsHandler)
 
 	// avatars.go
-	http.HandleFunc("/avatars/", avatarHandler)
+	http.HandleFunc(basePath+"/avatars/", instrumentHandler("avatars", avatarHandler))
+
+	// icon_proxy.go
+	http.HandleFunc(basePath+"/icons/", instrumentHandler("icons", iconProxyHandler))
 
 	// statuses.go
-	http.HandleFunc("/statusCheck/", statusCheckHandler)
+	http.HandleFunc(basePath+"/statusCheck/", instrumentHandler("statusCheck", statusCheckHandler))
+
+	// schema.go
+	http.HandleFunc(basePath+"/schema/", instrumentHandler("schema", compressHandler(schemaHandler)))
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Serve the file using the file server
-		log.Info("Filesystem request: ", r.Method, " ", r.URL.Path)
-		http.FileServer(http.Dir(compiledVuePath)).ServeHTTP(w, r)
-	})
+	// health.go, kept at root so probes don't depend on basePath
+	http.HandleFunc("/healthz", markSampled(healthzHandler))
+	http.HandleFunc("/readyz", markSampled(readyzHandler))
 
-	// Specify the port to listen on
-	port := 8080
-	addr := fmt.Sprintf(":%d", port)
+	// metrics.go, also at root for scraping
+	http.HandleFunc("/metrics", markSampled(metricsHandler))
+
+	// compression.go
+	fileServer := http.StripPrefix(basePath, staticFilesHandler(compiledVuePath))
+	http.HandleFunc(basePath+"/", instrumentHandler("files", markSampled(fileServer.ServeHTTP)))
+
+	if basePath != "" {
+		// frontend uses relative URLs, so it has to be loaded from a path ending with slash
+		http.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+	}
+
+	// Specify the address to listen on
+	server := config.get().Server
+	addr := net.JoinHostPort(server.Listen, strconv.Itoa(server.Port))
 
 	// Start the HTTP server
-	log.Info("Server is running on ", addr)
-	err := http.ListenAndServe(addr, nil)
+	var err error
+	if server.TLS.CertFile != "" {
+		log.Info("Server is running on ", addr, " at path ", basePath+"/", " with TLS")
+		// tls.go
+		handler := http.Handler(http.DefaultServeMux)
+		if server.TLS.ClientCAFile != "" {
+			handler = clientCertificateHandler(handler)
+		}
+		err = listenAndServeTLS(server, accessLogHandler(securityHeadersHandler(handler)))
+	} else {
+		log.Info("Server is running on ", addr, " at path ", basePath+"/")
+		// access_log.go, security_headers.go
+		err = http.ListenAndServe(addr, accessLogHandler(securityHeadersHandler(http.DefaultServeMux)))
+	}
 	if err != nil {
 		log.Fatal("Error starting server: ", err)
 	}
 }
 
 func entriesApiHandler(w http.ResponseWriter, r *http.Request) {
-	log.Info("Api request: ", r.Method, " ", r.URL.Path)
+	items, revision := dashboardItems.snapshot()
+
+	// revision changes with every item change, clients revalidate each time
+	etag := `"` + eventID(revision) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	// Set the content type to JSON
 	w.Header().Set("Content-Type", "application/json")
 
 	// Marshal the dashboardItems to JSON
-	jsonData, err := json.Marshal(dashboardItems.get())
+	jsonData, err := json.Marshal(items)
 	if err != nil {
+		requestLogger(r).Error("Error marshalling JSON: ", err)
 		http.Error(w, "Error marshalling JSON", http.StatusInternalServerError)
 		return
 	}
@@ -53,3 +120,37 @@ func entriesApiHandler(w http.ResponseWriter, r *http.Request) {
 	// Write the JSON data to the response writer
 	w.Write(jsonData)
 }
+
+// etagMatches checks If-None-Match header value against etag
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRecorder keeps status code and body size written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap gives http.ResponseController access to the original writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
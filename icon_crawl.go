@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 func crawlItem(name string) {
@@ -28,18 +29,25 @@ func crawlItem(name string) {
 
 	// echo metadata
 	log.Info("Starting icon crawl for '", name, "' at '", dashboardItem.URL)
+	itemStates.crawlStarted(name)
+	defer itemStates.crawlFinished(name)
 
 	findHtmlTitle(name, &dashboardItem)
 
+	// metrics.go
+	github := func(nameValue string) func() {
+		return func() { findIconGitHub(&dashboardItem, nameValue) }
+	}
+
 	for key, val := range dashboardItem.Labels {
 		// check for app.kubernetes.io/instance label
 		if key == "app.kubernetes.io/instance" {
-			findIconGitHub(&dashboardItem, val)
+			runIconStrategy("github", &dashboardItem, github(val))
 			log.Debug("GitHub icon search, based on '", val, "' returned: ", dashboardItem.IconURL)
 		}
 		// check for app.kubernetes.io/name label
 		if key == "app.kubernetes.io/name" {
-			findIconGitHub(&dashboardItem, val)
+			runIconStrategy("github", &dashboardItem, github(val))
 			log.Debug("GitHub icon search, based on '", val, "' returned: ", dashboardItem.IconURL)
 		}
 	}
@@ -47,40 +55,40 @@ func crawlItem(name string) {
 
 	// check Icons on GitHub based on Ingress name
 	checkedNames[name] = true
-	findIconGitHub(&dashboardItem, strings.ToLower(name))
+	runIconStrategy("github", &dashboardItem, github(strings.ToLower(name)))
 
 	// check Icons on GitHub based on site title first word
 	titleFirstWord := firstWord(dashboardItem.WebpageTitle)
 	if !checkedNames[titleFirstWord] {
 		checkedNames[titleFirstWord] = true
-		findIconGitHub(&dashboardItem, titleFirstWord)
+		runIconStrategy("github", &dashboardItem, github(titleFirstWord))
 	}
 
 	// check Icons on GitHub based on site title spaces to dashes
 	titleWithDashes := strings.ToLower(strings.ReplaceAll(dashboardItem.WebpageTitle, " ", "-"))
 	if !checkedNames[titleWithDashes] {
 		checkedNames[titleWithDashes] = true
-		findIconGitHub(&dashboardItem, titleWithDashes)
+		runIconStrategy("github", &dashboardItem, github(titleWithDashes))
 	}
 
 	// check header for PNG
-	findHtmlIcon(&dashboardItem, "png")
+	runIconStrategy("html_png", &dashboardItem, func() { findHtmlIcon(&dashboardItem, "png") })
 
 	// check header for SVG
-	findHtmlIcon(&dashboardItem, "svg")
+	runIconStrategy("html_svg", &dashboardItem, func() { findHtmlIcon(&dashboardItem, "svg") })
 
 	// check header with https://pkg.go.dev/go.deanishe.net/favicon
-	findHtmlIconDeanishe(&dashboardItem)
+	runIconStrategy("favicon", &dashboardItem, func() { findHtmlIconDeanishe(&dashboardItem) })
 
 	// check for first level of DNS domain
 	addressPrefix := strings.Split(getHostFromURL(dashboardItem.URL), ".")[0]
 	if !checkedNames[addressPrefix] {
 		checkedNames[addressPrefix] = true
-		findIconGitHub(&dashboardItem, addressPrefix)
+		runIconStrategy("github", &dashboardItem, github(addressPrefix))
 	}
 
 	// last resort - generate avatar
-	getGeneratedIcon(&dashboardItem, name)
+	runIconStrategy("avatar", &dashboardItem, func() { getGeneratedIcon(&dashboardItem, name) })
 
 	// download if static mode
 	if *staticMode {
@@ -95,12 +103,24 @@ func crawlItem(name string) {
 }
 
 func refreshItems() {
+	var initialCrawl sync.WaitGroup
 	for _, name := range dashboardItems.getKeys() {
 		if *staticMode {
 			wg.Add(1)
 		}
-		go crawlItem(name)
+		initialCrawl.Add(1)
+		go func(name string) {
+			defer initialCrawl.Done()
+			crawlItem(name)
+		}(name)
 	}
+
+	// health.go
+	go func() {
+		initialCrawl.Wait()
+		readiness.set(readyStaticCrawl, true, "initial crawl finished")
+	}()
+
 	if *staticMode {
 		wg.Wait()
 
@@ -24,14 +24,19 @@ func initHttpClient(tlsSkipVerify bool) {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsSkipVerify},
 	}
-	httpClient = &http.Client{
-		Transport: tr,
+	httpClient.Store(&http.Client{
+		// metrics.go
+		Transport: instrumentedTransport{tr},
 		Timeout:   30 * time.Second,
 		CheckRedirect: func(r *http.Request, via []*http.Request) error {
 			r.URL.Opaque = r.URL.Path
 			return nil
 		},
-	}
+	})
+
+	// statuses.go, icon_proxy.go
+	initStatusCheckClient(tlsSkipVerify)
+	initIconProxyClient(tlsSkipVerify)
 }
 
 func downloadIcon(fullURLFile string) string {
@@ -93,13 +98,13 @@ func downloadIcon(fullURLFile string) string {
 
 	// Download content and save to file
 	log.Debug("Downloading icon from URL: ", downloadURL)
-	resp, err := httpClient.Get(downloadURL)
+	resp, err := httpClient.Load().Get(downloadURL)
 	if err != nil {
 		log.Error("Error downloading content: ", err)
 	}
 	defer resp.Body.Close()
 
 	size, err := io.Copy(file, resp.Body)
-	log.Debug("Downloaded file %s with size %d\n", compiledVuePath+"/"+fileName, size)
+	log.Debugf("Downloaded file %s with size %d", compiledVuePath+"/"+fileName, size)
 	return fileName
 }
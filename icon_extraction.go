@@ -3,6 +3,7 @@
 package main
 
 import (
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"go.deanishe.net/favicon"
 	"golang.org/x/net/html"
@@ -10,6 +11,20 @@ import (
 	"strings"
 )
 
+// checkIconURL checks that icon URL is reachable, icons aren't subject to item check settings
+func checkIconURL(iconURL string) (int, error) {
+	resp, err := httpClient.Load().Get(iconURL)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 && resp.StatusCode != 401 {
+		return resp.StatusCode, fmt.Errorf("failed to retrieve the content. Status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
 func findIconGitHub(entry *DashEntry, nameValue string) {
 	log.Debug("findIconGitHub looking up: ", nameValue)
 	if entry.IconURL != "" {
@@ -25,14 +40,14 @@ func findIconGitHub(entry *DashEntry, nameValue string) {
 	name := nameValue
 	ghUrl := "https://raw.githubusercontent.com/homarr-labs/dashboard-icons/main/"
 	svgUrl := ghUrl + "svg/" + name + ".svg"
-	_, err := checkUrlStatus(svgUrl)
+	_, err := checkIconURL(svgUrl)
 	if err == nil {
 		log.Debug("findIconGitHub function: found icon: ", svgUrl)
 		entry.IconURL = svgUrl
 		return
 	}
 	pngUrl := ghUrl + "png/" + name + ".png"
-	_, err = checkUrlStatus(pngUrl)
+	_, err = checkIconURL(pngUrl)
 	if err == nil {
 		log.Debug("findIconGitHub function: found icon: ", pngUrl)
 		entry.IconURL = pngUrl
@@ -48,7 +63,7 @@ func findHtmlTitle(name string, entry *DashEntry) {
 		return
 	}
 
-	resp, err := httpClient.Get(strings.TrimSpace(entry.URL))
+	resp, err := httpClient.Load().Get(strings.TrimSpace(entry.URL))
 	if err != nil {
 		log.Error("findHtmlTitle function error: ", err)
 		return
@@ -89,7 +104,7 @@ func findHtmlIcon(entry *DashEntry, format string) {
 		return
 	}
 
-	resp, err := httpClient.Get(strings.TrimSpace(entry.URL))
+	resp, err := httpClient.Load().Get(strings.TrimSpace(entry.URL))
 	if err != nil {
 		log.Error("findHtmlIcon function: http.Get error: ", err)
 		return
@@ -165,7 +180,7 @@ func findHtmlIcon(entry *DashEntry, format string) {
 	f(doc)
 	if format == "svg" && finalSvgUrl != "" {
 		prefixedFinalSvgUrl := addPrefix(entry.URL, finalSvgUrl)
-		_, err = checkUrlStatus(prefixedFinalSvgUrl)
+		_, err = checkIconURL(prefixedFinalSvgUrl)
 		if err != nil {
 			log.Warn("findHtmlIcon function: error reading icon URL for: ", prefixedFinalSvgUrl)
 			return
@@ -175,7 +190,7 @@ func findHtmlIcon(entry *DashEntry, format string) {
 	}
 	if format == "png" && finalPngUrl != "" {
 		prefixedFinalPngUrl := addPrefix(entry.URL, finalPngUrl)
-		_, err = checkUrlStatus(prefixedFinalPngUrl)
+		_, err = checkIconURL(prefixedFinalPngUrl)
 		if err != nil {
 			log.Warn("findHtmlIcon function: error reading icon URL for: ", prefixedFinalPngUrl)
 			return
@@ -194,7 +209,7 @@ func findHtmlIconDeanishe(entry *DashEntry) {
 	}
 
 	// return, if endpoint unreachable
-	_, err := httpClient.Get(strings.TrimSpace(entry.URL))
+	_, err := httpClient.Load().Get(strings.TrimSpace(entry.URL))
 	if err != nil {
 		log.Error("findHtmlIconDeanishe function: http.Get error: ", err)
 		return
@@ -224,7 +239,7 @@ func findHtmlIconDeanishe(entry *DashEntry) {
 	}
 
 	potentialIcon := icons[0].URL
-	_, err = checkUrlStatus(potentialIcon)
+	_, err = checkIconURL(potentialIcon)
 	if err != nil {
 		log.Warn("findHtmlIconDeanishe function: error reading icon URL for: ", entry.URL)
 		return
@@ -0,0 +1,131 @@
+// serving item icons from CasaVue's own origin, so the CSP img-src can be 'self' only
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// icons larger than this are refused
+	iconProxyMaxSize = 1 << 20
+
+	// how long fetched icons are served from memory
+	iconProxyCacheTTL = time.Hour
+)
+
+type proxiedIcon struct {
+	contentType string
+	body        []byte
+	fetchedAt   time.Time
+}
+
+// fetched icons by URL
+var iconProxyCache = struct {
+	sync.Mutex
+	icons map[string]proxiedIcon
+}{icons: map[string]proxiedIcon{}}
+
+// client of the icon proxy, refusing connections to statusChecks.deniedNetworks like status checks
+var iconProxyClient atomic.Pointer[http.Client]
+
+func initIconProxyClient(tlsSkipVerify bool) {
+	iconProxyClient.Store(&http.Client{
+		Transport: &http.Transport{
+			// statuses.go
+			DialContext:     statusCheckDialer.DialContext,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsSkipVerify},
+		},
+		Timeout: 10 * time.Second,
+	})
+}
+
+// knownIconURL reports whether target is the absolute icon URL of a current item, so the
+// proxy can't be used to fetch other resources
+func knownIconURL(target string) bool {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	items, _ := dashboardItems.snapshot()
+	for _, entry := range items {
+		if entry.IconURL == target {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchIcon returns icon at target, from memory when fetched recently
+func fetchIcon(target string) (proxiedIcon, error) {
+	now := time.Now()
+	iconProxyCache.Lock()
+	icon, cached := iconProxyCache.icons[target]
+	iconProxyCache.Unlock()
+	if cached && now.Sub(icon.fetchedAt) < iconProxyCacheTTL {
+		return icon, nil
+	}
+
+	resp, err := iconProxyClient.Load().Get(target)
+	if err != nil {
+		return icon, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return icon, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	// other content would be served from CasaVue's origin, e.g. HTML pages
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(contentType, "image/") {
+		return icon, fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, iconProxyMaxSize+1))
+	if err != nil {
+		return icon, err
+	}
+	if len(body) > iconProxyMaxSize {
+		return icon, errors.New("icon larger than 1 MiB")
+	}
+
+	icon = proxiedIcon{contentType, body, now}
+	iconProxyCache.Lock()
+	defer iconProxyCache.Unlock()
+	for key, cachedIcon := range iconProxyCache.icons {
+		if now.Sub(cachedIcon.fetchedAt) >= iconProxyCacheTTL {
+			delete(iconProxyCache.icons, key)
+		}
+	}
+	iconProxyCache.icons[target] = icon
+	return icon, nil
+}
+
+// iconProxyHandler serves icon of a current item by its URL, with server.headers.proxyIcons set
+func iconProxyHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if !config.get().Server.Headers.ProxyIcons || !knownIconURL(target) {
+		http.NotFound(w, r)
+		return
+	}
+
+	icon, err := fetchIcon(target)
+	if err != nil {
+		requestLogger(r).Debug("Error fetching icon ", target, ": ", err)
+		http.Error(w, "Error fetching icon", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", icon.contentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	// scripts of SVG icons opened directly must not run in CasaVue's origin
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Write(icon.body)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIconProxyServesIconsOfCurrentItems(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/icon.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<script>alert(1)</script>"))
+		}
+	}))
+	defer backend.Close()
+
+	previous := config.get()
+	defer config.set(previous)
+	settings := Config{}
+	settings.Server.Headers.ProxyIcons = true
+	config.set(settings)
+	initIconProxyClient(false)
+	resetItemStores()
+	dashboardItems.write("app", DashEntry{URL: "https://app.example.com", IconURL: backend.URL + "/icon.png"})
+	dashboardItems.write("page", DashEntry{URL: "https://page.example.com", IconURL: backend.URL + "/page"})
+
+	cases := []struct {
+		target string
+		status int
+	}{
+		{backend.URL + "/icon.png", http.StatusOK},
+		{backend.URL + "/page", http.StatusBadGateway},
+		{backend.URL + "/other.png", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		iconProxyHandler(recorder, httptest.NewRequest(http.MethodGet, "/icons/?url="+url.QueryEscape(c.target), nil))
+		if recorder.Code != c.status {
+			t.Errorf("%s: got %d, expected %d", c.target, recorder.Code, c.status)
+		}
+		if c.status == http.StatusOK && (recorder.Body.String() != "png" || recorder.Header().Get("Content-Type") != "image/png") {
+			t.Errorf("%s: unexpected icon %q of type %q", c.target, recorder.Body.String(), recorder.Header().Get("Content-Type"))
+		}
+	}
+
+	// policy allows icons from CasaVue only when proxied, from their origins otherwise
+	if policy := contentSecurityPolicy(settings.Server.Headers); !strings.Contains(policy, "img-src 'self' data:;") {
+		t.Errorf("expected img-src limited to 'self' with proxied icons, got %q", policy)
+	}
+	settings.Server.Headers.ProxyIcons = false
+	config.set(settings)
+	if policy := contentSecurityPolicy(settings.Server.Headers); !strings.Contains(policy, "img-src 'self' data: "+backend.URL+";") {
+		t.Errorf("expected img-src with icon origin, got %q", policy)
+	}
+	recorder := httptest.NewRecorder()
+	iconProxyHandler(recorder, httptest.NewRequest(http.MethodGet, "/icons/?url="+url.QueryEscape(backend.URL+"/icon.png"), nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected 404 with proxy disabled, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,207 @@
+// Kubernetes integration reading Knative Service resources
+
+package main
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+var knativeServiceResource = schema.GroupVersionResource{
+	Group:    "serving.knative.dev",
+	Version:  "v1",
+	Resource: "services",
+}
+
+// isKnativeClusterLocal reports whether a Knative Service is reachable only from inside the cluster
+func isKnativeClusterLocal(it *unstructured.Unstructured) bool {
+	labels := it.GetLabels()
+	for _, key := range []string{"networking.knative.dev/visibility", "serving.knative.dev/visibility"} {
+		if labels[key] == "cluster-local" {
+			return true
+		}
+	}
+	return false
+}
+
+// knativeServiceItemName returns the dashboard key of a Knative Service
+func knativeServiceItemName(it *unstructured.Unstructured) string {
+	if val, ok := it.GetAnnotations()["casavue.app/name"]; ok && val != "" {
+		return val
+	}
+	return it.GetName()
+}
+
+func createDashEntryFromKnativeService(it *unstructured.Unstructured) (string, DashEntry) {
+	name := knativeServiceItemName(it)
+	description := ""
+	iconURL := ""
+
+	URL, _, _ := unstructured.NestedString(it.Object, "status", "url")
+
+	desc, _, iconOverride, urlOverride := processAnnotations(it.GetAnnotations())
+
+	if desc != "" {
+		description = desc
+	}
+	if iconOverride != "" {
+		iconURL = iconOverride
+	}
+	if urlOverride != "" {
+		URL = urlOverride
+	}
+
+	// checks.go
+	check := annotationCheck(it.GetAnnotations())
+
+	log.Info("Adding Dashboard Item based on knative service '", it.GetName(), "', with key '", name, "'.")
+	return name, DashEntry{it.GetNamespace(), description, URL, "", iconURL, it.GetLabels(), nil, check}
+}
+
+// skipKnativeService applies Knative specific rules to a Service
+func skipKnativeService(service *unstructured.Unstructured) bool {
+	// skip self
+	if service.GetLabels()["app.kubernetes.io/name"] == "casavue" {
+		log.Debug("Skipping self: ", service.GetName())
+		return true
+	}
+
+	if isKnativeClusterLocal(service) {
+		log.Debug("Skipping cluster-local knative service '" + service.GetName() + "'")
+		return true
+	}
+
+	if url, _, _ := unstructured.NestedString(service.Object, "status", "url"); url == "" {
+		log.Debug("Skipping knative service '" + service.GetName() + "' with no status URL yet")
+		return true
+	}
+	return false
+}
+
+// knativeServiceItems returns filtered and overridden item of a Knative Service
+func knativeServiceItems(service *unstructured.Unstructured) map[string]DashEntry {
+	name, entry := createDashEntryFromKnativeService(service)
+
+	// overrides.go
+	return prepareEntries(func(entry DashEntry) filterSubject {
+		return objectFilterSubject(sourceKnativeService, service, entry.URL)
+	}, map[string]DashEntry{name: entry})
+}
+
+// syncKnativeService writes item of a Knative Service, crawling it when new or changed
+func syncKnativeService(service *unstructured.Unstructured) {
+	ref := sourceRef(sourceKnativeService, service.GetNamespace(), service.GetName())
+	if skipKnativeService(service) {
+		sourceItems.remove(ref)
+		return
+	}
+	for _, name := range sourceItems.replace(ref, knativeServiceItems(service)) {
+		go crawlItem(name)
+	}
+}
+
+func getAndWatchKnativeServices(kconfig *rest.Config) {
+	log.Info("Getting Knative Services")
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kconfig)
+	if err != nil {
+		log.Warn("Error creating K8s discovery config: ", err)
+		readiness.set(sourceKnativeService, false, err.Error())
+		return
+	}
+
+	// Check if Knative Service resource is available
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(knativeServiceResource.GroupVersion().String())
+	if err != nil {
+		log.Info("Could not query for server resources in serving.knative.dev/v1, skipping Knative watch: ", err)
+		readiness.set(sourceKnativeService, true, "Knative Serving not available, skipped")
+		return
+	}
+	serviceSupported := false
+	for _, resource := range resources.APIResources {
+		if resource.Name == "services" && resource.Kind == "Service" {
+			serviceSupported = true
+			break
+		}
+	}
+	if !serviceSupported {
+		log.Info("Knative Service resource not available on the cluster, skipping Knative watch.")
+		readiness.set(sourceKnativeService, true, "Knative Service resource not available, skipped")
+		return
+	}
+
+	client, err := dynamic.NewForConfig(kconfig)
+	if err != nil {
+		log.Warn("Error creating K8s dynamic config: ", err)
+		readiness.set(sourceKnativeService, false, err.Error())
+		return
+	}
+
+	resourceClient := client.Resource(knativeServiceResource).Namespace(metav1.NamespaceAll)
+	watchlist := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return resourceClient.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return resourceClient.Watch(context.TODO(), options)
+		},
+	}
+	handlers := cache.ResourceEventHandlerFuncs{
+
+		AddFunc: func(obj interface{}) {
+			informerEventsTotal.inc(sourceKnativeService, "add")
+			service := obj.(*unstructured.Unstructured)
+			log.Info("Knative Service added: ", service.GetName())
+			syncKnativeService(service)
+		},
+
+		DeleteFunc: func(obj interface{}) {
+			informerEventsTotal.inc(sourceKnativeService, "delete")
+			service, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			log.Info("Knative Service deleted: ", service.GetName())
+			sourceItems.remove(sourceRef(sourceKnativeService, service.GetNamespace(), service.GetName()))
+		},
+
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			informerEventsTotal.inc(sourceKnativeService, "update")
+			oldService := oldObj.(*unstructured.Unstructured)
+			newService := newObj.(*unstructured.Unstructured)
+			log.Info("Knative Service updated: ", oldService.GetName(), " -> ", newService.GetName())
+			syncKnativeService(newService)
+		},
+	}
+	store, controller := cache.NewInformer(watchlist, &unstructured.Unstructured{}, time.Second*0, handlers)
+
+	// re-evaluate filters and overrides of known objects when configuration changes
+	registerSourceResync(func() {
+		for _, obj := range store.List() {
+			syncKnativeService(obj.(*unstructured.Unstructured))
+		}
+	})
+
+	stop := make(chan struct{})
+	go controller.Run(stop)
+	if cache.WaitForCacheSync(stop, controller.HasSynced) {
+		// health.go
+		readiness.set(sourceKnativeService, true, "informer cache synced")
+	}
+	for {
+		time.Sleep(time.Second)
+	}
+
+}
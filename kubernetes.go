@@ -3,7 +3,9 @@
 package main
 
 import (
+	"maps"
 	"os"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -19,11 +21,16 @@ import (
 	gatewayversioned "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
 )
 
+// name of the cluster items are discovered in, kubeconfig context or "in-cluster"
+var kubeContextName string
+
 func getKubeConfig(kubeconfigPath string) *rest.Config {
 	// creates the in-cluster config
 	kconfig, err := rest.InClusterConfig()
 	if err != nil {
 		log.Warn("Error creating K8s in-cluster config: ", err)
+	} else {
+		kubeContextName = "in-cluster"
 	}
 
 	if _, err := os.Stat(kubeconfigPath); err == nil {
@@ -31,10 +38,21 @@ func getKubeConfig(kubeconfigPath string) *rest.Config {
 		if err != nil {
 			log.Warn("Error building K8s config form flags: ", err)
 		}
+		if rawConfig, err := clientcmd.LoadFromFile(kubeconfigPath); err == nil {
+			kubeContextName = rawConfig.CurrentContext
+		}
 	}
 	return kconfig
 }
 
+// clusterName returns cluster name shown with Kubernetes items
+func clusterName() string {
+	if name := config.get().Cluster.Name; name != "" {
+		return name
+	}
+	return kubeContextName
+}
+
 func processAnnotations(annotations map[string]string) (description, nameOverride, iconURL, urlOverride string) {
 	if val, ok := annotations["casavue.app/description"]; ok {
 		log.Debug("Found description: ", val)
@@ -55,34 +73,145 @@ func processAnnotations(annotations map[string]string) (description, nameOverrid
 	return
 }
 
-func createDashEntryFromIngress(it *v1.Ingress) (string, DashEntry) {
-	protocol := "http://"
-	name := it.Name
-	description := ""
-	iconURL := ""
+// ingressPath is a single host and path pair exposed by an Ingress rule
+type ingressPath struct {
+	host string
+	path string
+}
+
+func collectIngressPaths(it *v1.Ingress) []ingressPath {
+	var result []ingressPath
+	seen := map[ingressPath]bool{}
 
+	for _, rule := range it.Spec.Rules {
+		if rule.Host == "" {
+			continue
+		}
+
+		paths := []string{""}
+		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+			paths = nil
+			for _, p := range rule.HTTP.Paths {
+				// regex paths can't be turned into a clickable URL
+				if isRegexPath(p.Path) {
+					log.Debug("Skipping regex-like path '", p.Path, "' of ingress '", it.Name, "'")
+					continue
+				}
+				path := strings.TrimRight(p.Path, "/")
+				if p.PathType != nil && *p.PathType == v1.PathTypeExact {
+					path = p.Path
+				}
+				paths = append(paths, path)
+			}
+		}
+
+		for _, path := range paths {
+			entry := ingressPath{rule.Host, path}
+			if seen[entry] {
+				continue
+			}
+			seen[entry] = true
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func createDashEntriesFromIngress(it *v1.Ingress) map[string]DashEntry {
+	protocol := "http://"
 	if len(it.Spec.TLS) > 0 {
 		protocol = "https://"
 	}
-	URL := protocol + it.Spec.Rules[0].Host
 
 	desc, nameOverride, iconOverride, urlOverride := processAnnotations(it.Annotations)
 
-	if desc != "" {
-		description = desc
+	// checks.go, relative check URLs resolve against each path
+	check := annotationCheck(it.Annotations)
+
+	paths := collectIngressPaths(it)
+	if len(paths) == 0 && urlOverride == "" {
+		log.Debug("No usable host and path found in ingress '", it.Name, "'")
+		return nil
 	}
-	if nameOverride != "" {
-		name = nameOverride
+	if len(paths) == 0 {
+		paths = []ingressPath{{}}
 	}
-	if iconOverride != "" {
-		iconURL = iconOverride
+
+	// several paths sharing a host are named after their last path segment,
+	// or the whole path when several of them end with the same segment
+	hostCount := map[string]int{}
+	segmentCount := map[string]int{}
+	for _, p := range paths {
+		hostCount[p.host]++
+		segmentCount[p.host+"/"+nameFromPath(p.path)]++
 	}
-	if urlOverride != "" {
-		URL = urlOverride
+
+	result := make(map[string]DashEntry)
+	for _, p := range paths {
+		name := it.Name
+		if len(paths) > 1 && hostCount[p.host] > 1 {
+			if segment := nameFromPath(p.path); segment != "" {
+				name = segment
+				if segmentCount[p.host+"/"+segment] > 1 {
+					name = nameFromFullPath(p.path)
+				}
+			}
+		}
+		URL := protocol + p.host + p.path
+
+		// annotation overrides are unambiguous only for a single entry
+		if len(paths) == 1 {
+			if nameOverride != "" {
+				name = nameOverride
+			}
+			if urlOverride != "" {
+				URL = urlOverride
+			}
+		}
+
+		// same path on several hosts
+		if _, ok := result[name]; ok {
+			name = name + "-" + p.host
+		}
+
+		log.Info("Adding Dashboard Item based on ingress '", it.Name, "', with key '", name, "'.")
+		result[name] = DashEntry{it.Namespace, desc, URL, "", iconOverride, it.Labels, nil, check}
 	}
+	return result
+}
 
-	log.Info("Adding Dashboard Item based on ingress '", it.Name, "', with key '", name, "'.")
-	return name, DashEntry{it.Namespace, description, URL, "", iconURL, it.Labels}
+// ingressItems returns filtered and overridden items of an Ingress
+func ingressItems(ingress *v1.Ingress) map[string]DashEntry {
+	entries := createDashEntriesFromIngress(ingress)
+
+	// overrides.go
+	return prepareEntries(func(entry DashEntry) filterSubject {
+		subject := objectFilterSubject(sourceIngress, ingress, entry.URL)
+		if len(entries) > 1 {
+			// name and URL annotations aren't applied to several entries, so they
+			// don't take precedence over override rules either
+			subject.annotations = maps.Clone(subject.annotations)
+			delete(subject.annotations, "casavue.app/name")
+			delete(subject.annotations, "casavue.app/url")
+		}
+		return subject
+	}, entries)
+}
+
+// syncIngress writes items of an Ingress, crawling new and changed ones
+func syncIngress(ingress *v1.Ingress) {
+	ref := sourceRef(sourceIngress, ingress.Namespace, ingress.Name)
+
+	// skip self
+	if ingress.Labels["app.kubernetes.io/name"] == "casavue" {
+		log.Debug("Skipping self: ", ingress.Name)
+		sourceItems.remove(ref)
+		return
+	}
+
+	for _, name := range sourceItems.replace(ref, ingressItems(ingress)) {
+		go crawlItem(name)
+	}
 }
 
 func getAndWatchKubernetesIngressItems(kconfig *rest.Config) {
@@ -91,81 +220,50 @@ func getAndWatchKubernetesIngressItems(kconfig *rest.Config) {
 	clientset, err := kubernetes.NewForConfig(kconfig)
 	if err != nil {
 		log.Warn("Error creating K8s config: ", err)
+		readiness.set(sourceIngress, false, err.Error())
 		return
 	}
 
 	watchlist := cache.NewListWatchFromClient(clientset.NetworkingV1().RESTClient(), "ingresses", metav1.NamespaceAll, fields.Everything())
-	_, controller := cache.NewInformer(
-		watchlist,
-		&v1.Ingress{},
-		time.Second*0,
-		cache.ResourceEventHandlerFuncs{
-
-			AddFunc: func(obj interface{}) {
-				ingress := obj.(*v1.Ingress)
-				if applyFilter(config.Content_filters.Namespace.Pattern, config.Content_filters.Namespace.Mode, ingress.Namespace) {
-					log.Debug("Skipping namespace '" + ingress.Namespace + "' due to pattern")
-					return
-				}
-				if applyFilter(config.Content_filters.Item.Pattern, config.Content_filters.Item.Mode, ingress.Name) {
-					log.Debug("Skipping item '" + ingress.Name + "' due to pattern")
-					return
-				}
+	handlers := cache.ResourceEventHandlerFuncs{
 
-				// skip self
-				if val, ok := ingress.Labels["app.kubernetes.io/name"]; ok {
-					if val == "casavue" {
-						log.Debug("Skipping self: ", ingress.Name)
-						return
-					}
-				}
+		AddFunc: func(obj interface{}) {
+			informerEventsTotal.inc(sourceIngress, "add")
+			ingress := obj.(*v1.Ingress)
+			log.Info("Ingress added: ", ingress.Name)
+			syncIngress(ingress)
+		},
 
-				_, annotationPresent := ingress.Annotations["casavue.app/enable"]
-				if config.Content_filters.Item.Mode == "ingressAnnotation" && !annotationPresent {
-					log.Debug("Skipping item '" + ingress.Name + "' due to Ingress Annotation mode and lack of annotation.")
-					return
-				}
-				log.Info("Ingress added: ", ingress.Name)
-				name, dashboardItem := createDashEntryFromIngress(ingress)
-				dashboardItems.write(name, dashboardItem)
-				go crawlItem(name)
-			},
-
-			DeleteFunc: func(obj interface{}) {
-				ingress := obj.(*v1.Ingress)
-				log.Info("Ingress deleted: ", ingress.Name)
-				dashboardItems.delete(ingress.Name)
-			},
-
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				oldIngress := oldObj.(*v1.Ingress)
-				newIngress := newObj.(*v1.Ingress)
-
-				dashboardItems.delete(oldIngress.Name)
-
-				if applyFilter(config.Content_filters.Namespace.Pattern, config.Content_filters.Namespace.Mode, newIngress.Namespace) {
-					log.Debug("Skipping namespace '" + newIngress.Namespace + "' due to pattern")
-					return
-				}
-				if applyFilter(config.Content_filters.Item.Pattern, config.Content_filters.Item.Mode, newIngress.Name) {
-					log.Debug("Skipping name '" + newIngress.Name + "' due to pattern")
-					return
-				}
-				_, annotationPresent := newIngress.Annotations["casavue.app/enable"]
-				if config.Content_filters.Item.Mode == "ingressAnnotation" && !annotationPresent {
-					log.Debug("Skipping item '" + newIngress.Name + "' due to Ingress Annotation mode and lack of annotation.")
-					return
-				}
-				log.Info("Ingress updated: ", oldIngress.Name, " -> ", newIngress.Name)
-				name, dashboardItem := createDashEntryFromIngress(newIngress)
-				dashboardItems.write(name, dashboardItem)
-				go crawlItem(name)
-			},
+		DeleteFunc: func(obj interface{}) {
+			informerEventsTotal.inc(sourceIngress, "delete")
+			ingress := obj.(*v1.Ingress)
+			log.Info("Ingress deleted: ", ingress.Name)
+			sourceItems.remove(sourceRef(sourceIngress, ingress.Namespace, ingress.Name))
+		},
+
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			informerEventsTotal.inc(sourceIngress, "update")
+			oldIngress := oldObj.(*v1.Ingress)
+			newIngress := newObj.(*v1.Ingress)
+			log.Info("Ingress updated: ", oldIngress.Name, " -> ", newIngress.Name)
+			syncIngress(newIngress)
 		},
-	)
+	}
+	store, controller := cache.NewInformer(watchlist, &v1.Ingress{}, time.Second*0, handlers)
+
+	// re-evaluate filters and overrides of known objects when configuration changes
+	registerSourceResync(func() {
+		for _, obj := range store.List() {
+			syncIngress(obj.(*v1.Ingress))
+		}
+	})
 
 	stop := make(chan struct{})
 	go controller.Run(stop)
+	if cache.WaitForCacheSync(stop, controller.HasSynced) {
+		// health.go
+		readiness.set(sourceIngress, true, "informer cache synced")
+	}
 	for {
 		time.Sleep(time.Second)
 	}
@@ -200,8 +298,37 @@ func createDashEntryFromHTTPRoute(it *gatewayv1.HTTPRoute) (string, DashEntry) {
 		URL = urlOverride
 	}
 
+	// checks.go
+	check := annotationCheck(it.Annotations)
+
 	log.Info("Adding Dashboard Item based on httproute '", it.Name, "', with key '", name, "'.")
-	return name, DashEntry{it.Namespace, description, URL, "", iconURL, it.Labels}
+	return name, DashEntry{it.Namespace, description, URL, "", iconURL, it.Labels, nil, check}
+}
+
+// httpRouteItems returns filtered and overridden item of an HTTPRoute
+func httpRouteItems(route *gatewayv1.HTTPRoute) map[string]DashEntry {
+	name, entry := createDashEntryFromHTTPRoute(route)
+
+	// overrides.go
+	return prepareEntries(func(entry DashEntry) filterSubject {
+		return objectFilterSubject(sourceHTTPRoute, route, entry.URL)
+	}, map[string]DashEntry{name: entry})
+}
+
+// syncHTTPRoute writes item of an HTTPRoute, crawling it when new or changed
+func syncHTTPRoute(route *gatewayv1.HTTPRoute) {
+	ref := sourceRef(sourceHTTPRoute, route.Namespace, route.Name)
+
+	// skip self
+	if route.Labels["app.kubernetes.io/name"] == "casavue" {
+		log.Debug("Skipping self: ", route.Name)
+		sourceItems.remove(ref)
+		return
+	}
+
+	for _, name := range sourceItems.replace(ref, httpRouteItems(route)) {
+		go crawlItem(name)
+	}
 }
 
 func getAndWatchKubernetesGatewayRoutes(kconfig *rest.Config) {
@@ -210,6 +337,7 @@ func getAndWatchKubernetesGatewayRoutes(kconfig *rest.Config) {
 	clientset, err := gatewayversioned.NewForConfig(kconfig)
 	if err != nil {
 		log.Warn("Error creating Gateway API config: ", err)
+		readiness.set(sourceHTTPRoute, false, err.Error())
 		return
 	}
 
@@ -217,6 +345,7 @@ func getAndWatchKubernetesGatewayRoutes(kconfig *rest.Config) {
 	resources, err := clientset.Discovery().ServerResourcesForGroupVersion("gateway.networking.k8s.io/v1")
 	if err != nil {
 		log.Info("Could not query for server resources in gateway.networking.k8s.io/v1, skipping Gateway API watch: ", err)
+		readiness.set(sourceHTTPRoute, true, "Gateway API not available, skipped")
 		return
 	}
 	httpRouteSupported := false
@@ -228,81 +357,50 @@ func getAndWatchKubernetesGatewayRoutes(kconfig *rest.Config) {
 	}
 	if !httpRouteSupported {
 		log.Info("HTTPRoute resource not available on the cluster, skipping Gateway API watch.")
+		readiness.set(sourceHTTPRoute, true, "HTTPRoute resource not available, skipped")
 		return
 	}
 
 	watchlist := cache.NewListWatchFromClient(clientset.GatewayV1().RESTClient(), "httproutes", metav1.NamespaceAll, fields.Everything())
-	_, controller := cache.NewInformer(
-		watchlist,
-		&gatewayv1.HTTPRoute{},
-		time.Second*0,
-		cache.ResourceEventHandlerFuncs{
-
-			AddFunc: func(obj interface{}) {
-				route := obj.(*gatewayv1.HTTPRoute)
-				if applyFilter(config.Content_filters.Namespace.Pattern, config.Content_filters.Namespace.Mode, route.Namespace) {
-					log.Debug("Skipping namespace '" + route.Namespace + "' due to pattern")
-					return
-				}
-				if applyFilter(config.Content_filters.Item.Pattern, config.Content_filters.Item.Mode, route.Name) {
-					log.Debug("Skipping item '" + route.Name + "' due to pattern")
-					return
-				}
+	handlers := cache.ResourceEventHandlerFuncs{
 
-				// skip self
-				if val, ok := route.Labels["app.kubernetes.io/name"]; ok {
-					if val == "casavue" {
-						log.Debug("Skipping self: ", route.Name)
-						return
-					}
-				}
+		AddFunc: func(obj interface{}) {
+			informerEventsTotal.inc(sourceHTTPRoute, "add")
+			route := obj.(*gatewayv1.HTTPRoute)
+			log.Info("HTTPRoute added: ", route.Name)
+			syncHTTPRoute(route)
+		},
 
-				_, annotationPresent := route.Annotations["casavue.app/enable"]
-				if config.Content_filters.Item.Mode == "ingressAnnotation" && !annotationPresent {
-					log.Debug("Skipping item '" + route.Name + "' due to Ingress Annotation mode and lack of annotation.")
-					return
-				}
-				log.Info("HTTPRoute added: ", route.Name)
-				name, dashboardItem := createDashEntryFromHTTPRoute(route)
-				dashboardItems.write(name, dashboardItem)
-				go crawlItem(name)
-			},
-
-			DeleteFunc: func(obj interface{}) {
-				route := obj.(*gatewayv1.HTTPRoute)
-				log.Info("HTTPRoute deleted: ", route.Name)
-				dashboardItems.delete(route.Name)
-			},
-
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				oldRoute := oldObj.(*gatewayv1.HTTPRoute)
-				newRoute := newObj.(*gatewayv1.HTTPRoute)
-
-				dashboardItems.delete(oldRoute.Name)
-
-				if applyFilter(config.Content_filters.Namespace.Pattern, config.Content_filters.Namespace.Mode, newRoute.Namespace) {
-					log.Debug("Skipping namespace '" + newRoute.Namespace + "' due to pattern")
-					return
-				}
-				if applyFilter(config.Content_filters.Item.Pattern, config.Content_filters.Item.Mode, newRoute.Name) {
-					log.Debug("Skipping name '" + newRoute.Name + "' due to pattern")
-					return
-				}
-				_, annotationPresent := newRoute.Annotations["casavue.app/enable"]
-				if config.Content_filters.Item.Mode == "ingressAnnotation" && !annotationPresent {
-					log.Debug("Skipping item '" + newRoute.Name + "' due to Ingress Annotation mode and lack of annotation.")
-					return
-				}
-				log.Info("HTTPRoute updated: ", oldRoute.Name, " -> ", newRoute.Name)
-				name, dashboardItem := createDashEntryFromHTTPRoute(newRoute)
-				dashboardItems.write(name, dashboardItem)
-				go crawlItem(name)
-			},
+		DeleteFunc: func(obj interface{}) {
+			informerEventsTotal.inc(sourceHTTPRoute, "delete")
+			route := obj.(*gatewayv1.HTTPRoute)
+			log.Info("HTTPRoute deleted: ", route.Name)
+			sourceItems.remove(sourceRef(sourceHTTPRoute, route.Namespace, route.Name))
+		},
+
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			informerEventsTotal.inc(sourceHTTPRoute, "update")
+			oldRoute := oldObj.(*gatewayv1.HTTPRoute)
+			newRoute := newObj.(*gatewayv1.HTTPRoute)
+			log.Info("HTTPRoute updated: ", oldRoute.Name, " -> ", newRoute.Name)
+			syncHTTPRoute(newRoute)
 		},
-	)
+	}
+	store, controller := cache.NewInformer(watchlist, &gatewayv1.HTTPRoute{}, time.Second*0, handlers)
+
+	// re-evaluate filters and overrides of known objects when configuration changes
+	registerSourceResync(func() {
+		for _, obj := range store.List() {
+			syncHTTPRoute(obj.(*gatewayv1.HTTPRoute))
+		}
+	})
 
 	stop := make(chan struct{})
 	go controller.Run(stop)
+	if cache.WaitForCacheSync(stop, controller.HasSynced) {
+		// health.go
+		readiness.set(sourceHTTPRoute, true, "informer cache synced")
+	}
 	for {
 		time.Sleep(time.Second)
 	}
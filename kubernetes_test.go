@@ -0,0 +1,105 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIngressItemNames(t *testing.T) {
+	paths := func(values ...string) *v1.HTTPIngressRuleValue {
+		rule := &v1.HTTPIngressRuleValue{}
+		for _, value := range values {
+			rule.Paths = append(rule.Paths, v1.HTTPIngressPath{Path: value})
+		}
+		return rule
+	}
+	ingress := &v1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "default"},
+		Spec: v1.IngressSpec{Rules: []v1.IngressRule{
+			{Host: "example.com", IngressRuleValue: v1.IngressRuleValue{HTTP: paths("/apps/a", "/apps/b", "/team-a/grafana", "/team-b/grafana")}},
+		}},
+	}
+
+	items := createDashEntriesFromIngress(ingress)
+	expected := map[string]string{
+		"a":              "http://example.com/apps/a",
+		"b":              "http://example.com/apps/b",
+		"team-a-grafana": "http://example.com/team-a/grafana",
+		"team-b-grafana": "http://example.com/team-b/grafana",
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, got %v", len(expected), items)
+	}
+	for name, url := range expected {
+		if items[name].URL != url {
+			t.Errorf("item %q: expected URL %s, got %+v", name, url, items[name])
+		}
+	}
+}
+
+func TestIngressOverrides(t *testing.T) {
+	previous := config.get()
+	defer config.set(previous)
+	settings := Config{}
+	settings.Overrides.Precedence = "annotations"
+	settings.Overrides.Rules = []ItemOverride{
+		{Match: OverrideMatch{Host: "^example\\.com$"}, Set: OverrideValues{Name: "portal"}},
+	}
+	config.set(settings)
+
+	rule := func(host string, values ...string) v1.IngressRule {
+		value := &v1.HTTPIngressRuleValue{}
+		for _, path := range values {
+			value.Paths = append(value.Paths, v1.HTTPIngressPath{Path: path})
+		}
+		return v1.IngressRule{Host: host, IngressRuleValue: v1.IngressRuleValue{HTTP: value}}
+	}
+	ingress := &v1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "default", Annotations: map[string]string{"casavue.app/name": "apps"}},
+		Spec:       v1.IngressSpec{Rules: []v1.IngressRule{rule("example.com", "/a", "/b"), rule("other.com", "/c")}},
+	}
+
+	// the name annotation isn't applied to several entries, so it doesn't block the rule,
+	// and both entries renamed to the same name are told apart by their original names
+	items := ingressItems(ingress)
+	for _, name := range []string{"portal", "portal-b", "apps"} {
+		if _, ok := items[name]; !ok {
+			t.Errorf("expected item %q, got %v", name, slices.Collect(maps.Keys(items)))
+		}
+	}
+	if items["portal"].URL != "http://example.com/a" || items["portal-b"].URL != "http://example.com/b" {
+		t.Errorf("expected items in order of original names, got %v", items)
+	}
+
+	// applied to a single entry, the annotation wins
+	ingress.Spec.Rules = ingress.Spec.Rules[:1]
+	ingress.Spec.Rules[0].HTTP.Paths = ingress.Spec.Rules[0].HTTP.Paths[:1]
+	if items := ingressItems(ingress); len(items) != 1 || items["apps"].URL == "" {
+		t.Errorf("expected annotation name to take precedence, got %v", items)
+	}
+}
+
+func TestPrepareEntriesRenameOntoExistingName(t *testing.T) {
+	previous := config.get()
+	defer config.set(previous)
+	settings := Config{}
+	settings.Overrides.Rules = []ItemOverride{
+		{Match: OverrideMatch{Host: "^b\\.example\\.com$"}, Set: OverrideValues{Name: "a"}},
+	}
+	config.set(settings)
+
+	entries := map[string]DashEntry{
+		"a": {URL: "https://a.example.com"},
+		"b": {URL: "https://b.example.com"},
+	}
+	items := prepareEntries(func(entry DashEntry) filterSubject {
+		return filterSubject{source: sourceStatic, host: getHostFromURL(entry.URL)}
+	}, entries)
+	if items["a"].URL != "https://a.example.com" || items["a-b"].URL != "https://b.example.com" || len(items) != 2 {
+		t.Errorf("expected entry named 'a' to keep its name, got %v", items)
+	}
+}
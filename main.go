@@ -6,58 +6,110 @@ import (
 	_ "embed"
 	"flag"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 
 	//	"time"
 	"sync"
+	"sync/atomic"
+	"syscall"
 
+	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/util/homedir"
 )
 
 const (
-	configFilePath        = "./config/main.yaml"
-	itemsFilePath         = "./config/items.yaml"
-	staticFilesPath       = "./frontend"
 	generatedAvatarsPath  = "./avatars"
 	downloadedAvatarsPath = "./downloadedAvatars"
 	staticApiPath         = "/api/v1"
-	compiledVuePath       = staticFilesPath + "/dist"
-	sourceVuePath         = staticFilesPath + "/src"
+)
+
+// paths settable with CASAVUE_* environment variables and command-line flags
+var (
+	configFilePath  = envOrDefault("CASAVUE_CONFIG_FILE", "./config/main.yaml")
+	itemsFilePath   = envOrDefault("CASAVUE_ITEMS_FILE", "./config/items.yaml")
+	staticFilesPath = envOrDefault("CASAVUE_STATIC_FILES_PATH", "./frontend")
+	compiledVuePath string
+	sourceVuePath   string
 )
 
 //go:embed VERSION_APP.txt
 var version string
 
-var config Config
+var config ConfigStore
 var staticItems StaticItems
 var staticMode *bool
 var wg sync.WaitGroup
-var httpClient *http.Client
+
+// replaced on reload while crawls are running
+var httpClient atomic.Pointer[http.Client]
 
 //= &http.Client{
 //	Timeout: 5 * time.Second,
 //}
 
 func main() {
+	// secrets.go
+	log.AddHook(secretsRedactionHook{})
+
+	// config_validation.go
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidateCommand(os.Args[2:]))
+	}
+
+	// config_migration.go
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
+	// schema.go
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		os.Exit(runSchemaCommand(os.Args[2:]))
+	}
+
 	staticMode = flag.Bool("static", false, "Single shot static content dashboard generation.")
+	printConfig := flag.Bool("print-config", false, "Print effective configuration and exit.")
+	flag.StringVar(&configFilePath, "config-file", configFilePath, "Path to main configuration file (env: CASAVUE_CONFIG_FILE).")
+	flag.StringVar(&itemsFilePath, "items-file", itemsFilePath, "Path to static items file (env: CASAVUE_ITEMS_FILE).")
+	flag.StringVar(&staticFilesPath, "static-files-path", staticFilesPath, "Path to frontend files (env: CASAVUE_STATIC_FILES_PATH).")
+
+	var kubeconfigPath string
+	if home := homedir.HomeDir(); home != "" {
+		flag.StringVar(&kubeconfigPath, "kubeconfig", envOrDefault("CASAVUE_KUBECONFIG", filepath.Join(home, ".kube", "config")), "(Optional) absolute path to the kubeconfig file")
+	} else {
+		flag.StringVar(&kubeconfigPath, "kubeconfig", envOrDefault("CASAVUE_KUBECONFIG", ""), "absolute path to the kubeconfig file")
+	}
+
+	// config_overrides.go
+	registerConfigFlags(flag.CommandLine)
 	flag.Parse()
 
+	compiledVuePath = staticFilesPath + "/dist"
+	sourceVuePath = staticFilesPath + "/src"
+	initCustomizationPaths()
+
+	if *printConfig {
+		printEffectiveConfig()
+		return
+	}
+
 	dashboardItems.items = make(map[string]DashEntry)
+	dashboardItems.health = make(map[string]ItemHealth)
+
+	// health.go
+	readiness.expect(readyConfig, "loading configuration")
+	readiness.expect(readyStaticCrawl, "crawling items")
 
 	// config.go
 	loadConfig()
 
+	// http_server.go, server settings aren't hot reloaded
+	basePath = normalizeBasePath(config.get().Server.BasePath)
+
 	// icon_crawl.go
 	refreshItems()
 
-	var kubeconfigPath string
-	if home := homedir.HomeDir(); home != "" {
-		flag.StringVar(&kubeconfigPath, "kubeconfig", filepath.Join(home, ".kube", "config"), "(Optional) absolute path to the kubeconfig file")
-	} else {
-		flag.StringVar(&kubeconfigPath, "kubeconfig", "", "absolute path to the kubeconfig file")
-	}
-	flag.Parse()
-
 	// customization.go
 	updateFrontendFiles()
 
@@ -65,14 +117,40 @@ func main() {
 		return
 	}
 
+	// config_reload.go
+	go watchConfigFile()
+
+	// history.go, saved once more on termination, the file can be set on reload
+	go runHistoryPersistence()
+	go exitOnSignal(saveStatusHistory)
+
+	// status_scheduler.go
+	go runStatusChecks()
+
 	// kubernetes.go
 	kconfig := getKubeConfig(kubeconfigPath)
 	if kconfig == nil {
 		return
 	}
+	for _, source := range []string{sourceIngress, sourceHTTPRoute, sourceKnativeService} {
+		readiness.expect(source, "waiting for informer cache sync")
+	}
 	go getAndWatchKubernetesIngressItems(kconfig)
 	go getAndWatchKubernetesGatewayRoutes(kconfig)
+	go getAndWatchKnativeServices(kconfig)
 
 	// httpserver.go
 	initHttpServer()
 }
+
+// exitOnSignal runs shutdown functions on SIGINT or SIGTERM, then exits
+func exitOnSignal(shutdown ...func()) {
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGINT, syscall.SIGTERM)
+	received := <-terminate
+	log.Info("Received ", received, ", shutting down")
+	for _, function := range shutdown {
+		function()
+	}
+	os.Exit(0)
+}
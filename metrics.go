@@ -0,0 +1,227 @@
+// Prometheus metrics, written in text exposition format
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upper bounds of histogram buckets, in seconds
+var metricBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type metricSeries struct {
+	labelValues []string
+	value       float64
+
+	// histograms only
+	buckets []uint64
+	count   uint64
+}
+
+// metricVec is a counter or histogram with a fixed set of label names
+type metricVec struct {
+	sync.Mutex
+	name   string
+	help   string
+	kind   string
+	labels []string
+	series map[string]*metricSeries
+}
+
+func newCounterVec(name string, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, kind: "counter", labels: labels, series: make(map[string]*metricSeries)}
+}
+
+func newHistogramVec(name string, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, kind: "histogram", labels: labels, series: make(map[string]*metricSeries)}
+}
+
+// with returns series for label values, creating it when missing. Requires lock held.
+func (m *metricVec) with(labelValues []string) *metricSeries {
+	key := strings.Join(labelValues, "\x00")
+	series, ok := m.series[key]
+	if !ok {
+		series = &metricSeries{labelValues: labelValues}
+		if m.kind == "histogram" {
+			series.buckets = make([]uint64, len(metricBuckets))
+		}
+		m.series[key] = series
+	}
+	return series
+}
+
+func (m *metricVec) inc(labelValues ...string) {
+	m.Lock()
+	m.with(labelValues).value++
+	m.Unlock()
+}
+
+func (m *metricVec) observe(seconds float64, labelValues ...string) {
+	m.Lock()
+	defer m.Unlock()
+	series := m.with(labelValues)
+	series.value += seconds
+	series.count++
+	for i, bound := range metricBuckets {
+		if seconds <= bound {
+			series.buckets[i]++
+		}
+	}
+}
+
+func (m *metricVec) write(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := m.series[key]
+		labels := formatMetricLabels(m.labels, series.labelValues)
+		if m.kind == "counter" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, labels, formatMetricValue(series.value))
+			continue
+		}
+		for i, bound := range metricBuckets {
+			bucketLabels := formatMetricLabels(append(append([]string{}, m.labels...), "le"), append(append([]string{}, series.labelValues...), formatMetricValue(bound)))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, bucketLabels, series.buckets[i])
+		}
+		infLabels := formatMetricLabels(append(append([]string{}, m.labels...), "le"), append(append([]string{}, series.labelValues...), "+Inf"))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, infLabels, series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, labels, formatMetricValue(series.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, labels, series.count)
+	}
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatMetricLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + metricLabelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	informerEventsTotal = newCounterVec("casavue_informer_events_total",
+		"Kubernetes informer events by source and event type.", "source", "event")
+	iconCrawlDuration = newHistogramVec("casavue_icon_crawl_duration_seconds",
+		"Duration of icon crawl strategies by outcome.", "strategy", "outcome")
+	outboundRequestsTotal = newCounterVec("casavue_outbound_requests_total",
+		"Outbound HTTP requests by host and status code, 0 for failed requests.", "host", "code")
+	outboundRequestDuration = newHistogramVec("casavue_outbound_request_duration_seconds",
+		"Duration of outbound HTTP requests by host.", "host")
+	statusChecksTotal = newCounterVec("casavue_status_checks_total",
+		"Item status checks by result.", "result")
+	httpRequestDuration = newHistogramVec("casavue_http_request_duration_seconds",
+		"Duration of served HTTP requests by handler and status code.", "handler", "code")
+)
+
+var metricVecs = []*metricVec{
+	informerEventsTotal,
+	iconCrawlDuration,
+	outboundRequestsTotal,
+	outboundRequestDuration,
+	statusChecksTotal,
+	httpRequestDuration,
+}
+
+// writeItemsMetric writes current dashboard item count by source and namespace
+func writeItemsMetric(w io.Writer) {
+	counts := map[[2]string]int{}
+	for ref, keys := range sourceItems.get() {
+		source := strings.SplitN(ref, "/", 2)[0]
+		for _, key := range keys {
+			if entry, ok := dashboardItems.read(key); ok {
+				counts[[2]string{source, entry.Namespace}]++
+			}
+		}
+	}
+
+	labels := make([][2]string, 0, len(counts))
+	for label := range counts {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i][0]+"\x00"+labels[i][1] < labels[j][0]+"\x00"+labels[j][1]
+	})
+
+	fmt.Fprint(w, "# HELP casavue_items Dashboard items by source and namespace.\n# TYPE casavue_items gauge\n")
+	for _, label := range labels {
+		fmt.Fprintf(w, "casavue_items%s %d\n", formatMetricLabels([]string{"source", "namespace"}, label[:]), counts[label])
+	}
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	// checked per request, so metrics can be enabled by reloading configuration
+	if !config.get().Metrics.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeItemsMetric(w)
+	for _, metric := range metricVecs {
+		metric.write(w)
+	}
+}
+
+// instrumentHandler records latency of requests served by handler
+func instrumentHandler(name string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r)
+		httpRequestDuration.observe(time.Since(start).Seconds(), name, strconv.Itoa(recorder.status))
+	}
+}
+
+// instrumentedTransport records outbound requests made with httpClient
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(r)
+	outboundRequestDuration.observe(time.Since(start).Seconds(), r.URL.Host)
+	code := "0"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	outboundRequestsTotal.inc(r.URL.Host, code)
+	return resp, err
+}
+
+// runIconStrategy runs find unless an icon was already found, recording its duration and outcome
+func runIconStrategy(strategy string, entry *DashEntry, find func()) {
+	if entry.IconURL != "" {
+		return
+	}
+	start := time.Now()
+	find()
+	outcome := "not_found"
+	if entry.IconURL != "" {
+		outcome = "found"
+	}
+	iconCrawlDuration.observe(time.Since(start).Seconds(), strategy, outcome)
+}
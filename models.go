@@ -6,7 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
 // DashEntry represents the structure of each entry in the dashboard.
@@ -18,12 +23,22 @@ type DashEntry struct {
 	WebpageTitle string            `json:"title"`
 	IconURL      string            `json:"iconURL"`
 	Labels       map[string]string `json:"labels"`
+	Tags         []string          `json:"tags"`
+
+	// checks.go, left out of API responses as headers may hold credentials
+	Check *HealthCheck `json:"-"`
 }
 
 // thread safe store for items
 type DashboardItemsStore struct {
 	sync.RWMutex
 	items map[string]DashEntry
+
+	// latest scheduled status check results by item name
+	health map[string]ItemHealth
+
+	// incremented on every change, used as event ID
+	revision uint64
 }
 
 var dashboardItems DashboardItemsStore
@@ -55,14 +70,292 @@ func (cs *DashboardItemsStore) read(key string) (DashEntry, bool) {
 
 func (cs *DashboardItemsStore) write(key string, value DashEntry) {
 	cs.Lock()
+	defer cs.Unlock()
+	previous, exists := cs.items[key]
+	if exists && reflect.DeepEqual(previous, value) {
+		return
+	}
 	cs.items[key] = value
-	cs.Unlock()
+	if !sameCheck(previous, value) {
+		// results of the previous URL or check definition don't apply anymore
+		delete(cs.health, key)
+	}
+
+	// events.go
+	cs.revision++
+	kind := itemAdded
+	if exists {
+		kind = itemUpdated
+	}
+	itemEvents.publish(itemEvent{revision: cs.revision, kind: kind, name: key, entry: value})
 }
 
 func (cs *DashboardItemsStore) delete(key string) {
 	cs.Lock()
+	defer cs.Unlock()
+	if _, exists := cs.items[key]; !exists {
+		return
+	}
 	delete(cs.items, key)
-	cs.Unlock()
+	delete(cs.health, key)
+	itemStates.forget(key)
+
+	// events.go
+	cs.revision++
+	itemEvents.publish(itemEvent{revision: cs.revision, kind: itemDeleted, name: key})
+}
+
+// snapshot returns a copy of items with the revision it was taken at
+func (cs *DashboardItemsStore) snapshot() (map[string]DashEntry, uint64) {
+	cs.RLock()
+	defer cs.RUnlock()
+	result := make(map[string]DashEntry, len(cs.items))
+	for key, value := range cs.items {
+		result[key] = value
+	}
+	return result, cs.revision
+}
+
+// sameCheck reports whether both entries are checked the same way
+func sameCheck(a DashEntry, b DashEntry) bool {
+	return a.URL == b.URL && reflect.DeepEqual(a.Check, b.Check)
+}
+
+// checkTarget is what an item is checked with, its URL and check settings merged
+// with statusChecks.defaults
+type checkTarget struct {
+	url   string
+	check HealthCheck
+}
+
+// checkTargetOf returns what entry is checked with under the current configuration
+func checkTargetOf(entry DashEntry) checkTarget {
+	// checks.go
+	return checkTarget{entry.URL, effectiveCheck(entry)}
+}
+
+// setHealth stores check result of item, when the checked URL and effective definition,
+// including defaults, are still the item's ones. Changes of status or code are published
+// as events, latency alone isn't.
+func (cs *DashboardItemsStore) setHealth(key string, checked checkTarget, health ItemHealth) {
+	cs.Lock()
+	defer cs.Unlock()
+	if entry, exists := cs.items[key]; !exists || !reflect.DeepEqual(checkTargetOf(entry), checked) {
+		return
+	}
+	previous, known := cs.health[key]
+	cs.health[key] = health
+	if known && previous.Status == health.Status && previous.Code == health.Code {
+		return
+	}
+
+	// events.go
+	cs.revision++
+	itemEvents.publish(itemEvent{revision: cs.revision, kind: itemHealthChanged, name: key, health: health})
+}
+
+// readHealth returns latest check result of item, "unknown" when not checked yet
+func (cs *DashboardItemsStore) readHealth(key string) ItemHealth {
+	cs.RLock()
+	defer cs.RUnlock()
+	if health, ok := cs.health[key]; ok {
+		return health
+	}
+	return ItemHealth{Status: "unknown"}
+}
+
+// crawl state of items, not part of DashEntry
+type ItemStateStore struct {
+	sync.RWMutex
+	crawls map[string]ItemCrawl
+}
+
+type ItemCrawl struct {
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+type ItemHealth struct {
+	// "up", "down" or "unknown"
+	Status    string     `json:"status"`
+	Code      int        `json:"code,omitempty"`
+	LatencyMs float64    `json:"latencyMs,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checkedAt,omitempty"`
+}
+
+var itemStates = ItemStateStore{crawls: make(map[string]ItemCrawl)}
+
+func (ss *ItemStateStore) crawlStarted(name string) {
+	now := time.Now()
+	ss.Lock()
+	ss.crawls[name] = ItemCrawl{StartedAt: &now}
+	ss.Unlock()
+}
+
+func (ss *ItemStateStore) crawlFinished(name string) {
+	now := time.Now()
+	ss.Lock()
+	crawl := ss.crawls[name]
+	crawl.FinishedAt = &now
+	ss.crawls[name] = crawl
+	ss.Unlock()
+}
+
+func (ss *ItemStateStore) read(name string) ItemCrawl {
+	ss.RLock()
+	defer ss.RUnlock()
+	return ss.crawls[name]
+}
+
+func (ss *ItemStateStore) forget(name string) {
+	ss.Lock()
+	delete(ss.crawls, name)
+	ss.Unlock()
+}
+
+// items created from each source object
+type SourceItemsStore struct {
+	sync.Mutex
+	// entries as created from the object, before crawling, by requested name
+	entries map[string]map[string]DashEntry
+	// keys of dashboard items written for the object, by requested name
+	keys map[string]map[string]string
+}
+
+var sourceItems = SourceItemsStore{entries: make(map[string]map[string]DashEntry), keys: make(map[string]map[string]string)}
+
+// sourceRef identifies the object dashboard items were created from
+func sourceRef(source string, namespace string, name string) string {
+	return source + "/" + namespace + "/" + name
+}
+
+// get returns a copy of item keys by source object
+func (ss *SourceItemsStore) get() map[string][]string {
+	ss.Lock()
+	defer ss.Unlock()
+	result := make(map[string][]string, len(ss.keys))
+	for ref, keys := range ss.keys {
+		for _, key := range keys {
+			result[ref] = append(result[ref], key)
+		}
+	}
+	return result
+}
+
+// sameCrawlInput reports whether crawling both entries gives the same icon and title
+func sameCrawlInput(a DashEntry, b DashEntry) bool {
+	return a.URL == b.URL && a.IconURL == b.IconURL && reflect.DeepEqual(a.Labels, b.Labels)
+}
+
+// qualifiedKey returns key of a name claimed by several objects, for all but its owner
+func qualifiedKey(name string, ref string, claimants []string) string {
+	parts := strings.SplitN(ref, "/", 3)
+	namespace, object := parts[1], parts[2]
+	sharedNamespace := 0
+	for _, claimant := range claimants {
+		if strings.SplitN(claimant, "/", 3)[1] == namespace {
+			sharedNamespace++
+		}
+	}
+	if namespace == "" {
+		return name + "-" + object
+	}
+	if sharedNamespace > 1 {
+		return name + "-" + namespace + "-" + object
+	}
+	return name + "-" + namespace
+}
+
+// assignKeys returns item keys of every object by requested name. A name claimed by
+// several objects keeps its key for the first ref in order and is qualified for the
+// others, so the result doesn't depend on the order objects were seen in.
+func (ss *SourceItemsStore) assignKeys() map[string]map[string]string {
+	claimants := map[string][]string{}
+	for ref, entries := range ss.entries {
+		for name := range entries {
+			claimants[name] = append(claimants[name], ref)
+		}
+	}
+
+	result := make(map[string]map[string]string, len(ss.entries))
+	for ref, entries := range ss.entries {
+		keys := make(map[string]string, len(entries))
+		for name := range entries {
+			refs := claimants[name]
+			keys[name] = name
+			if len(refs) > 1 && slices.Min(refs) != ref {
+				keys[name] = qualifiedKey(name, ref, refs)
+			}
+		}
+		result[ref] = keys
+	}
+	return result
+}
+
+// replace swaps items previously created from ref with entries, returning keys of items
+// to crawl. Unchanged items keep their crawled icon, title and check results.
+func (ss *SourceItemsStore) replace(ref string, entries map[string]DashEntry) []string {
+	ss.Lock()
+	defer ss.Unlock()
+
+	previous := ss.entries[ref]
+	if len(entries) > 0 {
+		ss.entries[ref] = entries
+	} else {
+		delete(ss.entries, ref)
+	}
+	assigned := ss.assignKeys()
+
+	// objects with changed entries or keys, keys of others may change on collisions
+	changed := []string{ref}
+	for other, keys := range ss.keys {
+		if other != ref && !maps.Equal(keys, assigned[other]) {
+			changed = append(changed, other)
+		}
+	}
+
+	// items gone are deleted first, their key may be taken by another object
+	for _, changedRef := range changed {
+		for name, key := range ss.keys[changedRef] {
+			if assigned[changedRef][name] != key {
+				dashboardItems.delete(key)
+			}
+		}
+	}
+
+	var crawl []string
+	for _, changedRef := range changed {
+		before := ss.entries[changedRef]
+		if changedRef == ref {
+			before = previous
+		}
+		for name, entry := range ss.entries[changedRef] {
+			key := assigned[changedRef][name]
+			old, existed := before[name]
+			if existed && ss.keys[changedRef][name] == key && sameCrawlInput(old, entry) {
+				if current, ok := dashboardItems.read(key); ok {
+					entry.WebpageTitle = current.WebpageTitle
+					entry.IconURL = current.IconURL
+				}
+			} else {
+				crawl = append(crawl, key)
+			}
+			dashboardItems.write(key, entry)
+		}
+
+		if len(assigned[changedRef]) > 0 {
+			ss.keys[changedRef] = assigned[changedRef]
+		} else {
+			delete(ss.keys, changedRef)
+		}
+	}
+	return crawl
+}
+
+// remove deletes all items created from ref
+func (ss *SourceItemsStore) remove(ref string) {
+	ss.replace(ref, nil)
 }
 
 func exportConfigAsJSONFile(data map[string]DashEntry, filePath string) error {
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func resetItemStores() {
+	dashboardItems = DashboardItemsStore{items: map[string]DashEntry{}, health: map[string]ItemHealth{}}
+	sourceItems = SourceItemsStore{entries: map[string]map[string]DashEntry{}, keys: map[string]map[string]string{}}
+}
+
+func TestReplaceKeepsUnchangedItems(t *testing.T) {
+	resetItemStores()
+	ref := sourceRef(sourceIngress, "monitoring", "grafana")
+	entry := DashEntry{Namespace: "monitoring", URL: "https://grafana.example.com"}
+
+	if crawl := sourceItems.replace(ref, map[string]DashEntry{"grafana": entry}); !slices.Equal(crawl, []string{"grafana"}) {
+		t.Fatalf("expected new item to be crawled, got %v", crawl)
+	}
+	crawled := entry
+	crawled.IconURL = "https://example.com/grafana.svg"
+	crawled.WebpageTitle = "Grafana"
+	dashboardItems.write("grafana", crawled)
+	now := time.Now()
+	dashboardItems.setHealth("grafana", checkTargetOf(crawled), ItemHealth{Status: "up", Code: 200, CheckedAt: &now})
+	_, revision := dashboardItems.snapshot()
+
+	if crawl := sourceItems.replace(ref, map[string]DashEntry{"grafana": entry}); len(crawl) != 0 {
+		t.Errorf("expected no crawl after identical update, got %v", crawl)
+	}
+	if got, _ := dashboardItems.read("grafana"); got.IconURL != crawled.IconURL || got.WebpageTitle != crawled.WebpageTitle {
+		t.Errorf("crawl result lost after identical update: %+v", got)
+	}
+	if health := dashboardItems.readHealth("grafana"); health.Status != "up" {
+		t.Errorf("health reset after identical update: %+v", health)
+	}
+	if _, current := dashboardItems.snapshot(); current != revision {
+		t.Errorf("identical update changed revision %d -> %d", revision, current)
+	}
+
+	described := entry
+	described.Description = "Dashboards"
+	if crawl := sourceItems.replace(ref, map[string]DashEntry{"grafana": described}); len(crawl) != 0 {
+		t.Errorf("expected no crawl after description change, got %v", crawl)
+	}
+	if got, _ := dashboardItems.read("grafana"); got.IconURL != crawled.IconURL || got.Description != "Dashboards" {
+		t.Errorf("unexpected item after description change: %+v", got)
+	}
+
+	moved := entry
+	moved.URL = "https://dashboards.example.com"
+	if crawl := sourceItems.replace(ref, map[string]DashEntry{"grafana": moved}); !slices.Equal(crawl, []string{"grafana"}) {
+		t.Errorf("expected crawl after URL change, got %v", crawl)
+	}
+}
+
+func TestReplaceResolvesCollisionsAcrossObjects(t *testing.T) {
+	first := sourceRef(sourceIngress, "monitoring", "grafana")
+	second := sourceRef(sourceIngress, "staging", "grafana")
+	firstEntry := DashEntry{Namespace: "monitoring", URL: "https://grafana.example.com"}
+	secondEntry := DashEntry{Namespace: "staging", URL: "https://grafana.staging.example.com"}
+
+	for _, order := range [][]string{{first, second}, {second, first}} {
+		resetItemStores()
+		for _, ref := range order {
+			entry := firstEntry
+			if ref == second {
+				entry = secondEntry
+			}
+			sourceItems.replace(ref, map[string]DashEntry{"grafana": entry})
+		}
+
+		if got, _ := dashboardItems.read("grafana"); got.URL != firstEntry.URL {
+			t.Errorf("order %v: expected grafana owned by %s, got %+v", order, first, got)
+		}
+		if got, _ := dashboardItems.read("grafana-staging"); got.URL != secondEntry.URL {
+			t.Errorf("order %v: expected qualified grafana-staging, got %+v", order, got)
+		}
+
+		sourceItems.remove(second)
+		if got, ok := dashboardItems.read("grafana"); !ok || got.URL != firstEntry.URL {
+			t.Errorf("order %v: removing %s deleted item of %s", order, second, first)
+		}
+		if _, ok := dashboardItems.read("grafana-staging"); ok {
+			t.Errorf("order %v: qualified item left after remove", order)
+		}
+	}
+}
+
+func TestQualifiedKey(t *testing.T) {
+	cases := []struct {
+		ref       string
+		claimants []string
+		expected  string
+	}{
+		{"ingress/staging/grafana", []string{"ingress/monitoring/grafana", "ingress/staging/grafana"}, "apps-staging"},
+		{"ingress/apps/b", []string{"ingress/apps/a", "ingress/apps/b"}, "apps-apps-b"},
+		{"static//b", []string{"static//a", "static//b"}, "apps-b"},
+	}
+	for _, c := range cases {
+		if got := qualifiedKey("apps", c.ref, c.claimants); got != c.expected {
+			t.Errorf("qualifiedKey(apps, %s) = %q, expected %q", c.ref, got, c.expected)
+		}
+	}
+}
+
+func TestSetHealthRejectsResultsOfChangedDefaults(t *testing.T) {
+	previous := config.get()
+	defer config.set(previous)
+	settings := Config{}
+	settings.StatusChecks.Defaults = HealthCheck{Method: "GET", Timeout: 30}
+	config.set(settings)
+	resetItemStores()
+
+	entry := DashEntry{URL: "https://grafana.example.com"}
+	dashboardItems.write("grafana", entry)
+	target := checkTargetOf(entry)
+
+	// defaults reloaded while the check was running
+	settings.StatusChecks.Defaults.Timeout = 5
+	config.set(settings)
+	now := time.Now()
+	dashboardItems.setHealth("grafana", target, ItemHealth{Status: "up", Code: 200, CheckedAt: &now})
+	if health := dashboardItems.readHealth("grafana"); health.Status != "unknown" {
+		t.Errorf("expected result of previous defaults to be dropped, got %+v", health)
+	}
+
+	dashboardItems.setHealth("grafana", checkTargetOf(entry), ItemHealth{Status: "up", Code: 200, CheckedAt: &now})
+	if health := dashboardItems.readHealth("grafana"); health.Status != "up" {
+		t.Errorf("expected result of current defaults to be stored, got %+v", health)
+	}
+}
@@ -0,0 +1,87 @@
+// TCP, DNS and TLS checks, for items not checked over HTTP
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runCheck runs check of the configured type, returning HTTP status code, 0 for other types
+func runCheck(logger *log.Entry, itemURL string, check HealthCheck) (int, error) {
+	switch check.Type {
+	case checkTCP:
+		return 0, checkTCPConnect(logger, itemURL, check)
+	case checkDNS:
+		return 0, checkDNSResolution(logger, itemURL, check)
+	case checkTLS:
+		return 0, checkTLSHandshake(logger, itemURL, check)
+	}
+	return checkUrlStatus(logger, itemURL, check)
+}
+
+// checkTCPConnect opens and closes a connection to the check address
+func checkTCPConnect(logger *log.Entry, itemURL string, check HealthCheck) error {
+	host, port, err := check.address(itemURL)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Checking TCP connection to ", net.JoinHostPort(host, port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), check.timeout())
+	defer cancel()
+	conn, err := statusCheckDialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkDNSResolution resolves the check host, it has to have at least one address
+func checkDNSResolution(logger *log.Entry, itemURL string, check HealthCheck) error {
+	host, _, err := check.address(itemURL)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Checking DNS resolution of ", host)
+
+	ctx, cancel := context.WithTimeout(context.Background(), check.timeout())
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil
+}
+
+// checkTLSHandshake completes a TLS handshake with the check address, verifying
+// its certificate unless allow_skip_tls_verify is set
+func checkTLSHandshake(logger *log.Entry, itemURL string, check HealthCheck) error {
+	host, port, err := check.address(itemURL)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Checking TLS handshake with ", net.JoinHostPort(host, port))
+
+	dialer := &tls.Dialer{
+		NetDialer: statusCheckDialer,
+		Config: &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: config.get().Allow_skip_tls_verify,
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), check.timeout())
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,284 @@
+// OpenAPI 3 description of the HTTP API, schemas generated from Go types
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const openAPIVersion = "3.0.3"
+
+// types described in components/schemas, referenced by name elsewhere
+var openAPIComponents = []reflect.Type{
+	reflect.TypeOf(DashEntry{}),
+	reflect.TypeOf(ItemV2{}),
+	reflect.TypeOf(ItemOrigin{}),
+	reflect.TypeOf(ItemCrawl{}),
+	reflect.TypeOf(ItemHealth{}),
+	reflect.TypeOf(NamespaceV2{}),
+	reflect.TypeOf(ItemHistory{}),
+	reflect.TypeOf(HistoryWindow{}),
+	reflect.TypeOf(LatencyPercentiles{}),
+	reflect.TypeOf(Outage{}),
+	reflect.TypeOf(HistorySample{}),
+	reflect.TypeOf(ReadinessCheck{}),
+}
+
+// jsonFieldName returns encoding/json name of field, "" for skipped fields
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || !field.IsExported() {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(options, "omitempty")
+}
+
+func openAPIRef(t reflect.Type) map[string]interface{} {
+	return componentRef(t.Name())
+}
+
+func componentRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// openAPISchemaOf describes t as encoded by encoding/json
+func openAPISchemaOf(t reflect.Type, root bool) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		// nil pointers are encoded as null, $ref can't have siblings so it's wrapped in allOf
+		schema := openAPISchemaOf(t.Elem(), false)
+		if _, ok := schema["$ref"]; ok {
+			schema = map[string]interface{}{"allOf": []interface{}{schema}}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": openAPISchemaOf(t.Elem(), false), "nullable": true}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": openAPISchemaOf(t.Elem(), false), "nullable": true}
+	case reflect.Struct:
+		for _, component := range openAPIComponents {
+			if !root && component == t {
+				return openAPIRef(t)
+			}
+		}
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			name, omitempty := jsonFieldName(t.Field(i))
+			if name == "" {
+				continue
+			}
+			properties[name] = openAPISchemaOf(t.Field(i).Type, false)
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}},
+	}
+}
+
+func queryParameter(name string, description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"name": name, "in": "query", "description": description, "schema": schema}
+}
+
+func getOperation(summary string, parameters []interface{}, responses map[string]interface{}) map[string]interface{} {
+	operation := map[string]interface{}{"summary": summary, "responses": responses}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	return map[string]interface{}{"get": operation}
+}
+
+// openAPIDocument returns the API description, with paths relative to basePath
+func openAPIDocument() map[string]interface{} {
+	stringSchema := map[string]interface{}{"type": "string"}
+	integerSchema := map[string]interface{}{"type": "integer", "minimum": 0}
+	errorSchema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"error": stringSchema},
+		"required":   []string{"error"},
+	}
+	textResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content":     map[string]interface{}{"text/plain": map[string]interface{}{"schema": stringSchema}},
+		}
+	}
+	// probes and metrics are served at root, regardless of basePath
+	rootServers := []interface{}{map[string]interface{}{"url": "/"}}
+
+	schemas := map[string]interface{}{"Error": errorSchema}
+	for _, component := range openAPIComponents {
+		schemas[component.Name()] = openAPISchemaOf(component, true)
+	}
+
+	paths := map[string]interface{}{
+		"/api/v1": getOperation("All items keyed by name", nil, map[string]interface{}{
+			"200": jsonResponse("Items", map[string]interface{}{"type": "object", "additionalProperties": openAPIRef(reflect.TypeOf(DashEntry{}))}),
+			"304": map[string]interface{}{"description": "Items unchanged since revision in If-None-Match"},
+		}),
+		"/api/v1/events": getOperation("Server-Sent Events stream of item changes: snapshot, add, update and delete events", []interface{}{
+			map[string]interface{}{"name": "Last-Event-ID", "in": "header", "description": "Resume after this event", "schema": stringSchema},
+		}, map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Event stream",
+				"content":     map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": stringSchema}},
+			},
+		}),
+		apiV2Path + "/items": getOperation("Items list", []interface{}{
+			queryParameter("namespace", "Only items in the group, can be repeated", stringSchema),
+			queryParameter("tag", "Only items with all given tags, can be repeated", stringSchema),
+			queryParameter("source", "Only items from the source, can be repeated", map[string]interface{}{"type": "string", "enum": sourceKinds}),
+			queryParameter("q", "Case-insensitive search in name, group, description, title and URL", stringSchema),
+			queryParameter("sort", "Comma-separated fields name, group, source, health, '-' prefix for descending order", stringSchema),
+			queryParameter("offset", "Items to skip", integerSchema),
+			queryParameter("limit", "Page size", map[string]interface{}{"type": "integer", "minimum": 0, "maximum": apiV2MaxLimit}),
+		}, map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Items, X-Total-Count header holds the number of matching items",
+				"headers":     map[string]interface{}{"X-Total-Count": map[string]interface{}{"schema": integerSchema}},
+				"content": map[string]interface{}{"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"type": "array", "items": openAPIRef(reflect.TypeOf(ItemV2{}))},
+				}},
+			},
+			"400": jsonResponse("Invalid query parameter", componentRef("Error")),
+		}),
+		apiV2Path + "/items/{id}/history": map[string]interface{}{"get": map[string]interface{}{
+			"summary": "Status check history of an item: uptime and latency percentiles over 24h, 7d and 30d, outages and recent checks",
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "id", "in": "path", "required": true, "schema": stringSchema},
+				queryParameter("samples", fmt.Sprintf("Recent checks returned, %d by default", historyDefaultSamples),
+					map[string]interface{}{"type": "integer", "minimum": 0, "maximum": apiV2MaxLimit}),
+			},
+			"responses": map[string]interface{}{
+				"200": jsonResponse("History", openAPIRef(reflect.TypeOf(ItemHistory{}))),
+				"400": jsonResponse("Invalid query parameter", componentRef("Error")),
+				"404": jsonResponse("No item with the ID", componentRef("Error")),
+			},
+		}},
+		apiV2Path + "/namespaces": getOperation("Groups with item counts", nil, map[string]interface{}{
+			"200": jsonResponse("Groups", map[string]interface{}{"type": "array", "items": openAPIRef(reflect.TypeOf(NamespaceV2{}))}),
+		}),
+		"/statusCheck/": getOperation("Latest status check result of an item, checked on demand when not checked yet", []interface{}{
+			queryParameter("id", "ID of the item to check, as returned by API v2", stringSchema),
+			queryParameter("url", "URL of the item to check, used when id isn't set", stringSchema),
+		}, map[string]interface{}{
+			"200": textResponse("Status code returned by the URL"),
+			"404": textResponse("No item with the ID or URL"),
+			"500": textResponse("URL unreachable, in a denied network or returned an error status"),
+		}),
+		"/icons/": getOperation("Icon of an item served by CasaVue, when enabled with server.headers.proxyIcons", []interface{}{
+			queryParameter("url", "Icon URL of a current item", stringSchema),
+		}, map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Icon image",
+				"content":     map[string]interface{}{"image/*": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}},
+			},
+			"404": textResponse("Icon proxy disabled, or no item with the icon URL"),
+			"502": textResponse("Icon unreachable, not an image or too large"),
+		}),
+		"/avatars/{name}": map[string]interface{}{"get": map[string]interface{}{
+			"summary": "Avatar generated for item name",
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "name", "in": "path", "required": true, "schema": stringSchema},
+			},
+			"responses": map[string]interface{}{"200": map[string]interface{}{
+				"description": "PNG image",
+				"content":     map[string]interface{}{"image/png": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}},
+			}},
+		}},
+		"/schema/{file}": map[string]interface{}{"get": map[string]interface{}{
+			"summary": "JSON Schema of configuration files",
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "file", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string", "enum": []string{"config.json", "items.json"}}},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "JSON Schema",
+					"content":     map[string]interface{}{"application/schema+json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}},
+				},
+				"404": textResponse("Unknown schema"),
+			},
+		}},
+		"/api/openapi.json": getOperation("This document", nil, map[string]interface{}{
+			"200": jsonResponse("OpenAPI document", map[string]interface{}{"type": "object"}),
+		}),
+		"/healthz": withServers(getOperation("Liveness probe", nil, map[string]interface{}{
+			"200": jsonResponse("Process is alive", map[string]interface{}{"type": "object", "properties": map[string]interface{}{"status": stringSchema}}),
+		}), rootServers),
+		"/readyz": withServers(getOperation("Readiness probe", nil, map[string]interface{}{
+			"200": jsonResponse("All checks passed", readinessSchema()),
+			"503": jsonResponse("Some checks didn't pass yet", readinessSchema()),
+		}), rootServers),
+		"/metrics": withServers(getOperation("Prometheus metrics, when enabled with metrics.enabled", nil, map[string]interface{}{
+			"200": textResponse("Metrics in Prometheus text format"),
+			"404": textResponse("Metrics disabled"),
+		}), rootServers),
+	}
+
+	serverURL := basePath
+	if serverURL == "" {
+		serverURL = "/"
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "CasaVue API",
+			"version": strings.TrimSpace(version),
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": serverURL}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func withServers(pathItem map[string]interface{}, servers []interface{}) map[string]interface{} {
+	pathItem["servers"] = servers
+	return pathItem
+}
+
+func readinessSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"status": map[string]interface{}{"type": "string", "enum": []string{"ready", "not ready"}},
+			"checks": map[string]interface{}{"type": "object", "additionalProperties": openAPIRef(reflect.TypeOf(ReadinessCheck{}))},
+		},
+	}
+}
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, openAPIDocument())
+}
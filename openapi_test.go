@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openAPIDocumentJSON returns the document as decoded JSON, as clients see it
+func openAPIDocumentJSON(t *testing.T) map[string]interface{} {
+	t.Helper()
+	encoded, err := json.Marshal(openAPIDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+// fillValue sets every field reachable from value to a non-zero value
+func fillValue(value reflect.Value) {
+	if value.Type() == reflect.TypeOf(time.Time{}) {
+		value.Set(reflect.ValueOf(time.Unix(1700000000, 0).UTC()))
+		return
+	}
+	switch value.Kind() {
+	case reflect.Ptr:
+		value.Set(reflect.New(value.Type().Elem()))
+		fillValue(value.Elem())
+	case reflect.String:
+		value.SetString("x")
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(1.5)
+	case reflect.Slice:
+		value.Set(reflect.MakeSlice(value.Type(), 1, 1))
+		fillValue(value.Index(0))
+	case reflect.Map:
+		value.Set(reflect.MakeMap(value.Type()))
+		element := reflect.New(value.Type().Elem()).Elem()
+		fillValue(element)
+		value.SetMapIndex(reflect.ValueOf("x").Convert(value.Type().Key()), element)
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				fillValue(value.Field(i))
+			}
+		}
+	}
+}
+
+// encodedKeys returns sorted JSON object keys of value
+func encodedKeys(t *testing.T, value interface{}) []string {
+	t.Helper()
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(encoded, &object); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedStrings(values interface{}) []string {
+	var result []string
+	if list, ok := values.([]interface{}); ok {
+		for _, value := range list {
+			result = append(result, value.(string))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// properties of component schemas match what encoding/json produces for the types,
+// fields always present are required
+func TestOpenAPIComponentsMatchTypes(t *testing.T) {
+	schemas := openAPIDocumentJSON(t)["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, component := range openAPIComponents {
+		schema, ok := schemas[component.Name()].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: missing from components", component.Name())
+			continue
+		}
+
+		full := reflect.New(component).Elem()
+		fillValue(full)
+		properties := make([]string, 0)
+		for name := range schema["properties"].(map[string]interface{}) {
+			properties = append(properties, name)
+		}
+		sort.Strings(properties)
+		if keys := encodedKeys(t, full.Interface()); !slices.Equal(properties, keys) {
+			t.Errorf("%s: properties %v, encoded fields %v", component.Name(), properties, keys)
+		}
+
+		zero := reflect.New(component).Elem().Interface()
+		if required, keys := sortedStrings(schema["required"]), encodedKeys(t, zero); !slices.Equal(required, keys) {
+			t.Errorf("%s: required %v, fields encoded when empty %v", component.Name(), required, keys)
+		}
+	}
+}
+
+// validateJSON checks decoded JSON value against an OpenAPI schema, objects
+// can't have properties the schema doesn't describe
+func validateJSON(path string, value interface{}, schema map[string]interface{}, schemas map[string]interface{}) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return validateJSON(path, value, schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{}), schemas)
+	}
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{path + ": null, schema isn't nullable"}
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		var problems []string
+		for _, part := range allOf {
+			problems = append(problems, validateJSON(path, value, part.(map[string]interface{}), schemas)...)
+		}
+		return problems
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !slices.Contains(enum, value) {
+		return []string{fmt.Sprintf("%s: %v not in %v", path, value, enum)}
+	}
+
+	mismatch := []string{fmt.Sprintf("%s: %T doesn't match type %v", path, value, schema["type"])}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		var problems []string
+		for _, name := range sortedStrings(schema["required"]) {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required %q", path, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for name, property := range object {
+			switch {
+			case properties[name] != nil:
+				problems = append(problems, validateJSON(path+"."+name, property, properties[name].(map[string]interface{}), schemas)...)
+			case additional != nil:
+				problems = append(problems, validateJSON(path+"."+name, property, additional, schemas)...)
+			case properties != nil:
+				problems = append(problems, fmt.Sprintf("%s: unexpected property %q", path, name))
+			}
+		}
+		return problems
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		var problems []string
+		for i, element := range array {
+			problems = append(problems, validateJSON(fmt.Sprintf("%s[%d]", path, i), element, schema["items"].(map[string]interface{}), schemas)...)
+		}
+		return problems
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return mismatch
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, text); err != nil {
+				return []string{fmt.Sprintf("%s: %q isn't date-time", path, text)}
+			}
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+			return mismatch
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return mismatch
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch
+		}
+	}
+	return nil
+}
+
+// responseSchema returns JSON schema of a documented response
+func responseSchema(t *testing.T, document map[string]interface{}, path string, status int) map[string]interface{} {
+	t.Helper()
+	operation, ok := document["paths"].(map[string]interface{})[path].(map[string]interface{})["get"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s: not documented", path)
+	}
+	response, ok := operation["responses"].(map[string]interface{})[fmt.Sprint(status)].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s: status %d not documented", path, status)
+	}
+	return response["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+}
+
+// responses of API handlers validate against the document
+func TestOpenAPIMatchesResponses(t *testing.T) {
+	previous := config.get()
+	defer config.set(previous)
+	config.set(Config{StatusChecks: StatusChecks{History: StatusHistory{Samples: 100}}})
+	resetItemStores()
+
+	// an unchecked static item with empty fields, and a checked, crawled Ingress item
+	sourceItems.replace(sourceRef(sourceStatic, "", "router"), map[string]DashEntry{
+		"router": {URL: "http://192.168.1.1"},
+	})
+	grafana := DashEntry{"monitoring", "Dashboards", "https://grafana.example.com", "Grafana", "https://example.com/grafana.svg",
+		map[string]string{"app.kubernetes.io/name": "grafana"}, []string{"metrics"}, &HealthCheck{URL: "/api/health"}}
+	sourceItems.replace(sourceRef(sourceIngress, "monitoring", "grafana"), map[string]DashEntry{"grafana": grafana})
+	itemStates.crawlStarted("grafana")
+	itemStates.crawlFinished("grafana")
+	for i, status := range []string{"up", "down", "up"} {
+		checkedAt := time.Now().Add(time.Duration(i-3) * time.Minute)
+		health := ItemHealth{Status: status, Code: 200, LatencyMs: 12.5, CheckedAt: &checkedAt}
+		if status == "down" {
+			health = ItemHealth{Status: status, Error: "connection refused", LatencyMs: 3, CheckedAt: &checkedAt}
+		}
+		dashboardItems.setHealth("grafana", checkTargetOf(grafana), health)
+		statusHistory.record("grafana", health)
+	}
+	readiness.expect("test", "waiting")
+
+	document := openAPIDocumentJSON(t)
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+	historyRequest := func(id string) *http.Request {
+		request := httptest.NewRequest(http.MethodGet, apiV2Path+"/items/"+id+"/history", nil)
+		request.SetPathValue("id", id)
+		return request
+	}
+	cases := []struct {
+		path    string
+		handler http.HandlerFunc
+		request *http.Request
+		status  int
+	}{
+		{"/api/v1", entriesApiHandler, httptest.NewRequest(http.MethodGet, "/api/v1", nil), http.StatusOK},
+		{apiV2Path + "/items", itemsV2Handler, httptest.NewRequest(http.MethodGet, apiV2Path+"/items", nil), http.StatusOK},
+		{apiV2Path + "/items", itemsV2Handler, httptest.NewRequest(http.MethodGet, apiV2Path+"/items?sort=size", nil), http.StatusBadRequest},
+		{apiV2Path + "/namespaces", namespacesV2Handler, httptest.NewRequest(http.MethodGet, apiV2Path+"/namespaces", nil), http.StatusOK},
+		{apiV2Path + "/items/{id}/history", itemHistoryHandler, historyRequest(itemID("grafana")), http.StatusOK},
+		{apiV2Path + "/items/{id}/history", itemHistoryHandler, historyRequest(itemID("router")), http.StatusOK},
+		{apiV2Path + "/items/{id}/history", itemHistoryHandler, historyRequest("unknown"), http.StatusNotFound},
+		{"/healthz", healthzHandler, httptest.NewRequest(http.MethodGet, "/healthz", nil), http.StatusOK},
+		{"/readyz", readyzHandler, httptest.NewRequest(http.MethodGet, "/readyz", nil), http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		c.handler(recorder, c.request)
+		if recorder.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.request.URL, c.status, recorder.Code)
+			continue
+		}
+		var body interface{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: invalid JSON: %s", c.request.URL, err)
+			continue
+		}
+		for _, problem := range validateJSON(c.request.URL.Path, body, responseSchema(t, document, c.path, c.status), schemas) {
+			t.Error(problem)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// per-item override rules from main.yaml, for items which can't be annotated
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OverrideMatch selects items by regex on namespace, name, host and labels
+type OverrideMatch struct {
+	Namespace string            `yaml:"namespace,omitempty" description:"Regex matching item namespace."`
+	Name      string            `yaml:"name,omitempty" description:"Regex matching source resource name."`
+	Host      string            `yaml:"host,omitempty" description:"Regex matching item URL host."`
+	Labels    map[string]string `yaml:"labels,omitempty" description:"Labels which must be present, with value regex."`
+}
+
+// OverrideValues holds item fields to be set, empty fields are left untouched
+type OverrideValues struct {
+	Name        string   `yaml:"name,omitempty" description:"Item name."`
+	Description string   `yaml:"description,omitempty" description:"Item description."`
+	Icon        string   `yaml:"icon,omitempty" description:"Item icon URL."`
+	URL         string   `yaml:"url,omitempty" description:"Item URL." format:"uri"`
+	Group       string   `yaml:"group,omitempty" description:"Group (namespace) the item is shown in."`
+	Tags        []string `yaml:"tags,omitempty" description:"Item tags."`
+	Hidden      *bool    `yaml:"hidden,omitempty" description:"Hides the item from dashboard."`
+	// merged field by field into check settings of the item
+	Check *HealthCheck `yaml:"check,omitempty" description:"Status check settings, fields set here replace those of the item."`
+}
+
+type ItemOverride struct {
+	Match OverrideMatch  `yaml:"match" description:"Items the rule applies to."`
+	Set   OverrideValues `yaml:"set" description:"Fields set on matching items."`
+}
+
+type Overrides struct {
+	// "overrides" or "annotations", decides which one wins when both set a field
+	Precedence string         `yaml:"precedence" description:"Which one wins when both annotation and override rule set a field." enum:"overrides,annotations"`
+	Rules      []ItemOverride `yaml:"rules" description:"Override rules, all matching rules are applied in order."`
+}
+
+func (match OverrideMatch) matches(subject filterSubject) bool {
+	rule := FilterRule{Namespace: match.Namespace, Name: match.Name, Host: match.Host, Labels: match.Labels}
+	return rule.matches(subject)
+}
+
+// applyOverrides updates item key and entry with all matching rules, in order.
+// Returns false when the item should be hidden.
+func applyOverrides(subject filterSubject, name *string, entry *DashEntry) bool {
+	overrides := config.get().Overrides
+	annotationsFirst := overrides.Precedence == "annotations"
+
+	// lower precedence overrides skip fields set with annotations
+	settable := func(annotation string) bool {
+		if !annotationsFirst {
+			return true
+		}
+		_, ok := subject.annotations[annotation]
+		return !ok
+	}
+
+	hidden := false
+	for idx, rule := range overrides.Rules {
+		if !rule.Match.matches(subject) {
+			continue
+		}
+		log.Debug("Applying override rule #", idx+1, " to '", *name, "'")
+
+		set := rule.Set
+		if set.Name != "" && settable("casavue.app/name") {
+			*name = set.Name
+		}
+		if set.Description != "" && settable("casavue.app/description") {
+			entry.Description = set.Description
+		}
+		if set.Icon != "" && settable("casavue.app/icon") {
+			entry.IconURL = set.Icon
+		}
+		if set.URL != "" && settable("casavue.app/url") {
+			entry.URL = set.URL
+		}
+		if set.Group != "" {
+			entry.Namespace = set.Group
+		}
+		if set.Tags != nil {
+			entry.Tags = set.Tags
+		}
+		if set.Hidden != nil {
+			hidden = *set.Hidden
+		}
+		if set.Check != nil && settable(checkAnnotation) {
+			// checks.go
+			entry.Check = mergeChecks(entry.Check, set.Check)
+		}
+	}
+	return !hidden
+}
+
+// prepareEntries applies content filters and overrides to items built from a source object.
+// A name given to several entries by override rules stays with the entry named so before
+// overrides, or the first one in order, and is qualified with the original name for others.
+func prepareEntries(subjectOf func(DashEntry) filterSubject, entries map[string]DashEntry) map[string]DashEntry {
+	renamed := make(map[string]DashEntry)
+	claimants := map[string][]string{}
+	for _, original := range slices.Sorted(maps.Keys(entries)) {
+		name, entry := original, entries[original]
+		subject := subjectOf(entry)
+
+		// content_filters.go
+		if !isIncludedByFilters(subject) {
+			continue
+		}
+		if !applyOverrides(subject, &name, &entry) {
+			log.Debug("Skipping '", name, "' hidden by override rule")
+			continue
+		}
+		renamed[original] = entry
+		claimants[name] = append(claimants[name], original)
+	}
+
+	result := make(map[string]DashEntry, len(renamed))
+	for name, originals := range claimants {
+		owner := originals[0]
+		if slices.Contains(originals, name) {
+			owner = name
+		}
+		for _, original := range originals {
+			key := name
+			if original != owner {
+				key = name + "-" + original
+				log.Warn("Override rules name several items '", name, "', item '", original, "' is named '", key, "'")
+			}
+			result[key] = renamed[original]
+		}
+	}
+	return result
+}
+
+func validateOverride(override ItemOverride) []string {
+	rule := FilterRule{
+		Namespace: override.Match.Namespace,
+		Name:      override.Match.Name,
+		Host:      override.Match.Host,
+		Labels:    override.Match.Labels,
+		Action:    "include",
+	}
+	problems := validateFilterRule(rule)
+
+	if override.Set.URL != "" {
+		if err := validateURL(override.Set.URL); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid url %q: %s", override.Set.URL, err))
+		}
+	}
+	if override.Set.Check != nil {
+		problems = append(problems, validateCheck(*override.Set.Check)...)
+	}
+	return problems
+}
@@ -81,6 +81,25 @@ func addPrefix(fqdn string, endpoint string) string {
 	return u.Scheme + "://" + u.Hostname() + "/" + strings.TrimLeft(endpoint, "/")
 }
 
+// isRegexPath reports whether an Ingress path looks like a regular expression
+func isRegexPath(path string) bool {
+	return strings.ContainsAny(path, `^$()[]{}|*+?\`)
+}
+
+// nameFromPath returns the last non-empty segment of an URL path
+func nameFromPath(path string) string {
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	if len(segments) == 0 {
+		return ""
+	}
+	return segments[len(segments)-1]
+}
+
+// nameFromFullPath returns all non-empty segments of an URL path joined with dashes
+func nameFromFullPath(path string) string {
+	return strings.Join(strings.FieldsFunc(path, func(r rune) bool { return r == '/' }), "-")
+}
+
 func strToSha256(input string) string {
 	hasher := sha256.New()
 	hasher.Write([]byte(input))
@@ -90,32 +109,6 @@ func strToSha256(input string) string {
 	return hex.EncodeToString(checksum)
 }
 
-func applyFilter(pattern string, mode string, str string) bool {
-	var exclude bool
-	if mode == "exclude" {
-		exclude = true
-	} else if mode == "include" {
-		exclude = false
-	} else if mode == "ingressAnnotation" {
-		return false
-	} else {
-		log.Fatal("Error parsing configuration. Only 'exclude', 'include' and 'ingressAnnotation' values are allowed as filter mode.")
-	}
-
-	matched, err := regexp.MatchString(pattern, str)
-	if err != nil {
-		log.Fatal("Parsing regular expression error:", err)
-	}
-
-	if exclude {
-		// when excluding, return true on match
-		return matched
-	} else {
-		// when including, return false on match
-		return !matched
-	}
-}
-
 // IsDigit returns true if the rune is a digit.
 func IsDigit(r rune) bool {
 	return unicode.IsDigit(r)
@@ -0,0 +1,145 @@
+// JSON Schema of configuration files, generated from Go types
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// jsonSchemaOf describes t, using yaml field names and description, enum,
+// pattern, format, minimum, maximum and required struct tags
+func jsonSchemaOf(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonSchemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("yaml") == "-" {
+				continue
+			}
+			name := yamlFieldName(field)
+			properties[name] = jsonSchemaOfField(field)
+			if field.Tag.Get("required") == "true" {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+func jsonSchemaOfField(field reflect.StructField) map[string]interface{} {
+	schema := jsonSchemaOf(field.Type)
+
+	// for lists, value constraints apply to list items
+	target := schema
+	if field.Type.Kind() == reflect.Slice {
+		target = schema["items"].(map[string]interface{})
+	}
+
+	if description := field.Tag.Get("description"); description != "" {
+		schema["description"] = description
+	}
+	if enum := field.Tag.Get("enum"); enum != "" {
+		target["enum"] = strings.Split(enum, ",")
+	}
+	for _, key := range []string{"pattern", "format"} {
+		if value := field.Tag.Get(key); value != "" {
+			target[key] = value
+		}
+	}
+	for _, key := range []string{"minimum", "maximum"} {
+		if value, err := strconv.Atoi(field.Tag.Get(key)); err == nil {
+			target[key] = value
+		}
+	}
+	return schema
+}
+
+func configJSONSchema() map[string]interface{} {
+	schema := jsonSchemaOf(reflect.TypeOf(Config{}))
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = "CasaVue main.yaml"
+	return schema
+}
+
+func itemsJSONSchema() map[string]interface{} {
+	schema := jsonSchemaOf(reflect.TypeOf(StaticItems{}))
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = "CasaVue items.yaml"
+	return schema
+}
+
+// runSchemaCommand implements 'casavue schema [config|items]', returning process exit code
+func runSchemaCommand(args []string) int {
+	schema := configJSONSchema()
+	if len(args) > 0 {
+		switch args[0] {
+		case "config":
+		case "items":
+			schema = itemsJSONSchema()
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown schema %q, allowed values: config, items\n", args[0])
+			return 2
+		}
+	}
+
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error marshalling schema:", err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
+
+func schemaHandler(w http.ResponseWriter, r *http.Request) {
+	var schema map[string]interface{}
+	switch strings.TrimPrefix(r.URL.Path, basePath+"/schema/") {
+	case "config.json":
+		schema = configJSONSchema()
+	case "items.json":
+		schema = itemsJSONSchema()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	jsonData, err := json.Marshal(schema)
+	if err != nil {
+		requestLogger(r).Error("Error marshalling JSON: ", err)
+		http.Error(w, "Error marshalling JSON", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(jsonData)
+}
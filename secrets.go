@@ -0,0 +1,145 @@
+// resolving ${env:NAME} and ${file:/path} secret references in configuration
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var secretReferenceRegex = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+const redactedValue = "******"
+
+// resolved secret values, redacted from logs
+var resolvedSecrets struct {
+	sync.RWMutex
+	values map[string]bool
+}
+
+func registerSecret(value string) {
+	if value == "" {
+		return
+	}
+	resolvedSecrets.Lock()
+	if resolvedSecrets.values == nil {
+		resolvedSecrets.values = make(map[string]bool)
+	}
+	resolvedSecrets.values[value] = true
+	resolvedSecrets.Unlock()
+}
+
+// redactSecrets replaces all known secret values in str
+func redactSecrets(str string) string {
+	resolvedSecrets.RLock()
+	defer resolvedSecrets.RUnlock()
+	for value := range resolvedSecrets.values {
+		str = strings.ReplaceAll(str, value, redactedValue)
+	}
+	return str
+}
+
+// resolveSecretReference returns value of a single ${env:...} or ${file:...} reference
+func resolveSecretReference(kind string, name string) (string, error) {
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s referenced in configuration is not set", name)
+		}
+		return value, nil
+	case "file":
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("error reading file referenced in configuration: %w", err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	return "", fmt.Errorf("unknown reference kind %q", kind)
+}
+
+func resolveSecretsInString(str string) (string, error) {
+	var resolveErr error
+	result := secretReferenceRegex.ReplaceAllStringFunc(str, func(reference string) string {
+		match := secretReferenceRegex.FindStringSubmatch(reference)
+		value, err := resolveSecretReference(match[1], match[2])
+		if err != nil {
+			resolveErr = err
+			return reference
+		}
+		registerSecret(value)
+		return value
+	})
+	return result, resolveErr
+}
+
+// resolveSecretsInValue walks all strings reachable from value, resolving references in place
+func resolveSecretsInValue(value reflect.Value) []error {
+	var errs []error
+	switch value.Kind() {
+	case reflect.Ptr:
+		if !value.IsNil() {
+			errs = append(errs, resolveSecretsInValue(value.Elem())...)
+		}
+	case reflect.String:
+		if !strings.Contains(value.String(), "${") {
+			return nil
+		}
+		resolved, err := resolveSecretsInString(value.String())
+		if err != nil {
+			return []error{err}
+		}
+		value.SetString(resolved)
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				errs = append(errs, resolveSecretsInValue(value.Field(i))...)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			errs = append(errs, resolveSecretsInValue(value.Index(i))...)
+		}
+	case reflect.Map:
+		// map values aren't addressable, so they are copied, resolved and stored back
+		for _, key := range value.MapKeys() {
+			item := reflect.New(value.Type().Elem()).Elem()
+			item.Set(value.MapIndex(key))
+			errs = append(errs, resolveSecretsInValue(item)...)
+			value.SetMapIndex(key, item)
+		}
+	}
+	return errs
+}
+
+// resolveConfigSecrets resolves references in configuration structure pointed by out
+func resolveConfigSecrets(file string, out interface{}) []configProblem {
+	var problems []configProblem
+	for _, err := range resolveSecretsInValue(reflect.ValueOf(out)) {
+		problems = append(problems, configProblem{file: file, message: err.Error()})
+	}
+	return problems
+}
+
+// secretsRedactionHook keeps resolved secret values out of log output
+type secretsRedactionHook struct{}
+
+func (hook secretsRedactionHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook secretsRedactionHook) Fire(entry *log.Entry) error {
+	entry.Message = redactSecrets(entry.Message)
+	for key, value := range entry.Data {
+		if str, ok := value.(string); ok {
+			entry.Data[key] = redactSecrets(str)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// security response headers and Content-Security-Policy
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// values allowed in server.headers.referrerPolicy
+var referrerPolicies = []string{
+	"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+	"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url",
+}
+
+// cached img-src origins, recomputed when items change
+var iconOrigins struct {
+	sync.Mutex
+	revision uint64
+	valid    bool
+	sources  string
+}
+
+// iconOriginSources returns CSP sources of all origins item icons are loaded from
+func iconOriginSources() string {
+	items, revision := dashboardItems.snapshot()
+
+	iconOrigins.Lock()
+	defer iconOrigins.Unlock()
+	if iconOrigins.valid && iconOrigins.revision == revision {
+		return iconOrigins.sources
+	}
+
+	origins := map[string]bool{}
+	for _, entry := range items {
+		u, err := url.Parse(entry.IconURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			// relative URLs are served by CasaVue itself
+			continue
+		}
+		origins[u.Scheme+"://"+u.Host] = true
+	}
+	sources := make([]string, 0, len(origins))
+	for origin := range origins {
+		sources = append(sources, origin)
+	}
+	sort.Strings(sources)
+
+	iconOrigins.revision = revision
+	iconOrigins.valid = true
+	iconOrigins.sources = strings.Join(sources, " ")
+	return iconOrigins.sources
+}
+
+// contentSecurityPolicy returns configured policy, or one allowing the frontend,
+// its service worker and icons of current items. The policy is computed for every
+// response, so icons of items added later are allowed from the next page load.
+func contentSecurityPolicy(headers SecurityHeaders) string {
+	if headers.ContentSecurityPolicy != "" {
+		return headers.ContentSecurityPolicy
+	}
+
+	// icon_proxy.go serves icons from CasaVue itself with proxyIcons set
+	imgSrc := "'self' data:"
+	if origins := iconOriginSources(); origins != "" && !headers.ProxyIcons {
+		imgSrc += " " + origins
+	}
+	frameAncestors := "'none'"
+	if len(headers.FrameAncestors) > 0 {
+		frameAncestors = strings.Join(headers.FrameAncestors, " ")
+	}
+
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self'",
+		// Font Awesome injects its stylesheet at runtime, title font comes from Google Fonts
+		"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com",
+		"img-src " + imgSrc,
+		"font-src 'self' data: https://fonts.gstatic.com",
+		"connect-src 'self'",
+		"manifest-src 'self'",
+		"worker-src 'self'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors " + frameAncestors,
+	}, "; ")
+}
+
+// securityHeadersHandler adds security headers to all responses
+func securityHeadersHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server := config.get().Server
+		headers := server.Headers
+		if headers.Enabled {
+			header := w.Header()
+			header.Set("Content-Security-Policy", contentSecurityPolicy(headers))
+			header.Set("X-Content-Type-Options", "nosniff")
+			if headers.ReferrerPolicy != "" {
+				header.Set("Referrer-Policy", headers.ReferrerPolicy)
+			}
+			if len(headers.FrameAncestors) == 0 {
+				// for browsers without CSP frame-ancestors support
+				header.Set("X-Frame-Options", "DENY")
+			}
+			if server.TLS.CertFile != "" && headers.HSTSMaxAge > 0 {
+				value := fmt.Sprintf("max-age=%d", headers.HSTSMaxAge)
+				if headers.HSTSIncludeSubdomains {
+					value += "; includeSubDomains"
+				}
+				header.Set("Strict-Transport-Security", value)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
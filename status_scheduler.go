@@ -0,0 +1,85 @@
+// background status checks of all items
+
+package main
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// how often the scheduler looks for items due to be checked
+const statusSchedulerTick = time.Second
+
+type scheduledCheck struct {
+	target checkTarget
+	next   time.Time
+}
+
+// statusCheckDelay returns time until the next check of an item, interval plus random jitter
+func statusCheckDelay(settings StatusChecks, withInterval bool) time.Duration {
+	var delay time.Duration
+	if withInterval {
+		delay = time.Duration(settings.Interval) * time.Second
+	}
+	if settings.Jitter > 0 {
+		delay += rand.N(time.Duration(settings.Jitter) * time.Second)
+	}
+	return delay
+}
+
+// runStatusChecks checks every item periodically, running at most
+// statusChecks.concurrency checks at once, settings are re-read on every tick
+func runStatusChecks() {
+	log.Info("Starting scheduled status checks")
+	schedule := map[string]scheduledCheck{}
+	running := map[string]bool{}
+	done := make(chan string)
+
+	ticker := time.NewTicker(statusSchedulerTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case name := <-done:
+			delete(running, name)
+			continue
+		case <-ticker.C:
+		}
+
+		settings := config.get().StatusChecks
+		if !settings.Enabled {
+			continue
+		}
+
+		items, _ := dashboardItems.snapshot()
+		for name := range schedule {
+			if _, exists := items[name]; !exists {
+				delete(schedule, name)
+			}
+		}
+
+		now := time.Now()
+		for name, entry := range items {
+			check, known := schedule[name]
+			target := checkTargetOf(entry)
+			if !known || !reflect.DeepEqual(check.target, target) {
+				// new and changed items, also by changed defaults, are checked right away,
+				// spread over the jitter window
+				schedule[name] = scheduledCheck{target, now.Add(statusCheckDelay(settings, false))}
+				continue
+			}
+			if now.Before(check.next) || running[name] || len(running) >= settings.Concurrency {
+				continue
+			}
+
+			running[name] = true
+			schedule[name] = scheduledCheck{target, now.Add(statusCheckDelay(settings, true))}
+			go func(name string, entry DashEntry) {
+				checkItem(log.WithField("item", name), name, entry)
+				done <- name
+			}(name, entry)
+		}
+	}
+}
@@ -3,67 +3,247 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
-	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
-	// "time"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
-func checkUrlStatus(url string) (int, error) {
-	//client := http.Client{
-	//	Timeout: 3 * time.Second,
-	//}
+// dialer used for status checks only, refusing connections to statusChecks.deniedNetworks
+var statusCheckDialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	// runs after name resolution, for every address tried, so DNS rebinding can't bypass it
+	Control: deniedNetworksControl,
+}
+
+// HTTP clients of status checks, replaced as a whole on reload while checks are running
+type statusCheckClients struct {
+	follow     *http.Client
+	noRedirect *http.Client
+}
+
+var statusCheckClient atomic.Pointer[statusCheckClients]
 
-	log.Debug("Checking URL ", url, " for status.")
-	for {
-		resp, err := httpClient.Get(url)
-		if err != nil {
-			return -1, err
+func initStatusCheckClient(tlsSkipVerify bool) {
+	tr := &http.Transport{
+		DialContext:     statusCheckDialer.DialContext,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsSkipVerify},
+	}
+	// timeouts are set per check
+	statusCheckClient.Store(&statusCheckClients{
+		follow: &http.Client{
+			// metrics.go
+			Transport: instrumentedTransport{tr},
+		},
+		noRedirect: &http.Client{
+			Transport: instrumentedTransport{tr},
+			CheckRedirect: func(r *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	})
+}
+
+// deniedNetworksControl refuses connections to addresses in statusChecks.deniedNetworks
+func deniedNetworksControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("connection to %s refused: not an IP address", address)
+	}
+	if denied, found := matchNetworks(ip, config.get().StatusChecks.DeniedNetworks); found {
+		return fmt.Errorf("connection to %s refused: address in denied network %s", address, denied)
+	}
+	return nil
+}
+
+// checkUrlStatus runs HTTP check of item URL, returning status code of the response
+func checkUrlStatus(logger *log.Entry, itemURL string, check HealthCheck) (int, error) {
+	target, err := check.target(itemURL)
+	if err != nil {
+		return -1, err
+	}
+	logger.Debug("Checking URL ", target, " for status.")
+
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), check.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return -1, err
+	}
+	for name, value := range check.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
 		}
-		defer resp.Body.Close()
+		req.Header.Set(name, value)
+	}
+
+	clients := statusCheckClient.Load()
+	client := clients.follow
+	if !check.followRedirects() {
+		client = clients.noRedirect
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	if !check.expects(resp.StatusCode) {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if check.BodyContains == "" && check.BodyRegex == "" {
+		return resp.StatusCode, nil
+	}
 
-		if resp.StatusCode == 200 || resp.StatusCode == 401 {
-			return resp.StatusCode, nil
+	body, err := io.ReadAll(io.LimitReader(resp.Body, statusCheckMaxBody))
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("error reading response body: %w", err)
+	}
+	if check.BodyContains != "" && !bytes.Contains(body, []byte(check.BodyContains)) {
+		return resp.StatusCode, fmt.Errorf("response body doesn't contain %q", check.BodyContains)
+	}
+	if check.BodyRegex != "" {
+		bodyRegex, err := regexp.Compile(check.BodyRegex)
+		if err != nil {
+			return resp.StatusCode, err
+		}
+		if !bodyRegex.Match(body) {
+			return resp.StatusCode, fmt.Errorf("response body doesn't match %q", check.BodyRegex)
 		}
+	}
+	return resp.StatusCode, nil
+}
+
+// checkItem checks URL of item and stores the result, logging with logger of the
+// request or scheduler the check runs for
+func checkItem(logger *log.Entry, name string, entry DashEntry) ItemHealth {
+	start := time.Now()
+	target := checkTargetOf(entry)
+	// network_checks.go
+	statusCode, err := runCheck(logger, target.url, target.check)
+	health := ItemHealth{
+		Status:    "up",
+		Code:      max(statusCode, 0),
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: &start,
+	}
+	if err != nil {
+		logger.Debug("Request to ", entry.URL, ", ended with status: ", err)
+		health.Status = "down"
+		health.Error = err.Error()
+	}
+
+	// metrics.go
+	statusChecksTotal.inc(health.Status)
+	dashboardItems.setHealth(name, target, health)
+
+	// history.go
+	statusHistory.record(name, health)
+	return health
+}
 
-		if resp.StatusCode < 300 || resp.StatusCode >= 400 {
-			return resp.StatusCode, fmt.Errorf("failed to retrieve the content. Status code: %d", resp.StatusCode)
+// statusCheckTarget returns item addressed by id, or by url when it belongs to a known item
+func statusCheckTarget(query url.Values) (string, DashEntry, bool) {
+	id, target := query.Get("id"), query.Get("url")
+	items, _ := dashboardItems.snapshot()
+	for name, entry := range items {
+		if id != "" && itemID(name) == id {
+			return name, entry, true
 		}
+		if id == "" && target != "" && entry.URL == target {
+			return name, entry, true
+		}
+	}
+	return "", DashEntry{}, false
+}
+
+// outdatedHealth reports whether a check result has to be refreshed on demand, when it's
+// missing, or older than the interval while scheduled checks don't refresh it
+func outdatedHealth(health ItemHealth, settings StatusChecks, now time.Time) bool {
+	if health.CheckedAt == nil {
+		return true
+	}
+	return !settings.Enabled && now.Sub(*health.CheckedAt) >= time.Duration(settings.Interval)*time.Second
+}
 
-		url = resp.Header.Get("Location")
-		if url == "" {
-			return -1, fmt.Errorf("redirect location not found")
+// items checked on demand, so readers of outdated health don't check the same item twice
+var onDemandChecks = struct {
+	sync.Mutex
+	running map[string]bool
+}{running: map[string]bool{}}
+
+// refreshOutdatedHealth checks items with outdated results in the background while scheduled
+// checks are disabled, at most statusChecks.concurrency at once. Results reach clients as
+// health events and in the next item list.
+func refreshOutdatedHealth(logger *log.Entry) {
+	settings := config.get().StatusChecks
+	if settings.Enabled {
+		return
+	}
+
+	items, _ := dashboardItems.snapshot()
+	now := time.Now()
+	onDemandChecks.Lock()
+	defer onDemandChecks.Unlock()
+	for name, entry := range items {
+		if len(onDemandChecks.running) >= settings.Concurrency {
+			// remaining items are checked on the next refresh
+			return
 		}
+		if onDemandChecks.running[name] || !outdatedHealth(dashboardItems.readHealth(name), settings, now) {
+			continue
+		}
+		onDemandChecks.running[name] = true
+		go func(name string, entry DashEntry) {
+			checkItem(logger.WithField("item", name), name, entry)
+			onDemandChecks.Lock()
+			delete(onDemandChecks.running, name)
+			onDemandChecks.Unlock()
+		}(name, entry)
 	}
 }
 
+// statusCheckHandler returns the latest check result of an item, checking it on demand
+// when it wasn't checked yet, or is outdated with scheduled checks disabled
 func statusCheckHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract the URL from the request path
-	extractedUrl := ""
-
-	queryParams, err := url.ParseQuery(r.URL.RawQuery)
-	for key, values := range queryParams {
-		for _, value := range values {
-			if key == "url" {
-				extractedUrl = value
-			}
-		}
+	// only URLs of known items are checked, so the endpoint can't be used to probe other hosts
+	name, entry, ok := statusCheckTarget(r.URL.Query())
+	if !ok {
+		requestLogger(r).Debug("Status check of unknown item requested: ", r.URL.RawQuery)
+		http.Error(w, "Unknown item, expected id or url of an existing item", http.StatusNotFound)
+		return
 	}
 
-	if extractedUrl == "" {
-		fmt.Fprintf(w, "%d", 0)
+	health := dashboardItems.readHealth(name)
+	if outdatedHealth(health, config.get().StatusChecks, time.Now()) {
+		health = checkItem(requestLogger(r).WithField("item", name), name, entry)
 	}
 
-	// Make an HTTP request to the specified URL
-	statusCode, err := checkUrlStatus(extractedUrl)
-	if err != nil {
-		log.Warn("Request to ", extractedUrl, ", ended with status: ", err)
-		http.Error(w, fmt.Sprintf("Error making request to %s: %s", extractedUrl, err), http.StatusInternalServerError)
+	if health.Status != "up" {
+		requestLogger(r).WithField("item", name).Debug("Item is ", health.Status, ": ", health.Error)
+		http.Error(w, fmt.Sprintf("Error making request to %s: %s", entry.URL, health.Error), http.StatusInternalServerError)
 		return
 	}
-
-	// Return the status code as the response
-	log.Debug("Request to ", extractedUrl, ", ended with status: ", statusCode)
-	fmt.Fprintf(w, "%d", statusCode)
+	fmt.Fprintf(w, "%d", health.Code)
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutdatedHealth(t *testing.T) {
+	now := time.Now()
+	checkedAt := now.Add(-2 * time.Minute)
+	checked := ItemHealth{Status: "up", Code: 200, CheckedAt: &checkedAt}
+
+	cases := []struct {
+		health   ItemHealth
+		settings StatusChecks
+		expected bool
+	}{
+		{ItemHealth{Status: "unknown"}, StatusChecks{Enabled: true, Interval: 60}, true},
+		{checked, StatusChecks{Enabled: true, Interval: 60}, false},
+		{checked, StatusChecks{Enabled: false, Interval: 60}, true},
+		{checked, StatusChecks{Enabled: false, Interval: 300}, false},
+	}
+	for _, c := range cases {
+		if got := outdatedHealth(c.health, c.settings, now); got != c.expected {
+			t.Errorf("outdatedHealth(%+v, %+v) = %v, expected %v", c.health, c.settings, got, c.expected)
+		}
+	}
+}
+
+func TestStatusCheckHandlerRefreshesWithoutScheduler(t *testing.T) {
+	disabledSchedulerBackend(t)
+
+	recorder := httptest.NewRecorder()
+	statusCheckHandler(recorder, httptest.NewRequest(http.MethodGet, "/statusCheck/?id="+itemID("backend"), nil))
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expected outdated result to be checked again and fail, got %d %q", recorder.Code, recorder.Body.String())
+	}
+	if health := dashboardItems.readHealth("backend"); health.Status != "down" || health.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected refreshed health to be stored, got %+v", health)
+	}
+}
+
+// disabledSchedulerBackend sets up an item of a failing backend, last checked two days ago
+// while scheduled checks are disabled
+func disabledSchedulerBackend(t *testing.T) {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(backend.Close)
+
+	previous := config.get()
+	t.Cleanup(func() { config.set(previous) })
+	config.set(Config{StatusChecks: StatusChecks{Enabled: false, Interval: 60, Concurrency: 4}})
+	initStatusCheckClient(false)
+	resetItemStores()
+
+	entry := DashEntry{URL: backend.URL}
+	dashboardItems.write("backend", entry)
+	checkedAt := time.Now().Add(-48 * time.Hour)
+	dashboardItems.setHealth("backend", checkTargetOf(entry), ItemHealth{Status: "up", Code: 200, CheckedAt: &checkedAt})
+}
+
+func TestItemsV2RefreshesHealthWithoutScheduler(t *testing.T) {
+	disabledSchedulerBackend(t)
+
+	recorder := httptest.NewRecorder()
+	itemsV2Handler(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/items", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected items, got %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for dashboardItems.readHealth("backend").Status != "down" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected outdated item to be checked again, got %+v", dashboardItems.readHealth("backend"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	recorder = httptest.NewRecorder()
+	itemsV2Handler(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/items", nil))
+	if !strings.Contains(recorder.Body.String(), `"status":"down"`) {
+		t.Errorf("expected refreshed health in item list, got %s", recorder.Body.String())
+	}
+}
+
+func TestItemEventsRefreshHealthWithoutScheduler(t *testing.T) {
+	disabledSchedulerBackend(t)
+
+	server := httptest.NewServer(http.HandlerFunc(itemEventsHandler))
+	defer server.Close()
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	// the outdated result follows the snapshot, the refreshed one comes as a later event
+	done := make(chan bool)
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "data: ") && strings.Contains(scanner.Text(), `"status":"down"`) {
+				done <- true
+				return
+			}
+		}
+		done <- false
+	}()
+	select {
+	case found := <-done:
+		if !found {
+			t.Error("events stream ended without refreshed health")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected health event of outdated item checked again")
+	}
+}
@@ -0,0 +1,223 @@
+// native HTTPS serving, with certificate reload and optional client certificates
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// certificateStore holds currently served certificate and client CAs
+type certificateStore struct {
+	sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+var serverCertificates certificateStore
+
+func (s *certificateStore) load(settings TLS) error {
+	cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+	if err != nil {
+		return fmt.Errorf("error loading certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if settings.ClientCAFile != "" {
+		content, err := os.ReadFile(settings.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("error reading client CA bundle: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(content) {
+			return errors.New("no PEM certificates found in client CA bundle '" + settings.ClientCAFile + "'")
+		}
+	}
+
+	s.Lock()
+	s.cert = &cert
+	s.clientCAs = clientCAs
+	s.Unlock()
+	return nil
+}
+
+// tlsConfig returns server configuration always using the latest loaded files
+func (s *certificateStore) tlsConfig(settings TLS) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			s.RLock()
+			defer s.RUnlock()
+			return s.cert, nil
+		},
+	}
+	if settings.ClientCAFile != "" {
+		// verified here instead of with ClientCAs, so a reloaded bundle applies to new connections.
+		// Not required in the handshake, so probes can reach health endpoints, see clientCertificateHandler.
+		cfg.ClientAuth = tls.RequestClientCert
+		cfg.VerifyPeerCertificate = s.verifyClientCertificate
+	}
+	return cfg
+}
+
+func (s *certificateStore) verifyClientCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	var certs []*x509.Certificate
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		// rejected per request by clientCertificateHandler
+		return nil
+	}
+
+	s.RLock()
+	clientCAs := s.clientCAs
+	s.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         clientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		log.Warn("Rejected client certificate '", certs[0].Subject, "': ", err)
+	}
+	return err
+}
+
+// paths served without client certificate, for kubelet and load balancer probes
+var clientCertificateExemptPaths = []string{"/healthz", "/readyz"}
+
+// clientCertificateHandler rejects requests of connections without a verified client
+// certificate, apart from health endpoints
+func clientCertificateHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.PeerCertificates) == 0 && !slices.Contains(clientCertificateExemptPaths, r.URL.Path) {
+			requestLogger(r).Debug("Rejected request without client certificate")
+			http.Error(w, "client certificate required", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func readTLSChecksum(settings TLS) string {
+	var content []byte
+	for _, path := range []string{settings.CertFile, settings.KeyFile, settings.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		content = append(content, data...)
+	}
+	return strToSha256(string(content))
+}
+
+// watchCertificateFiles reloads certificates renewed e.g. by cert-manager or certbot
+func watchCertificateFiles(settings TLS) {
+	checksum := readTLSChecksum(settings)
+	for {
+		time.Sleep(configWatchInterval)
+
+		current := readTLSChecksum(settings)
+		if current == checksum || current == "" {
+			continue
+		}
+		checksum = current
+
+		log.Info("Certificate files changed, reloading.")
+		if err := serverCertificates.load(settings); err != nil {
+			// renewals may write cert and key in separate steps, retried on next change
+			log.Error("Error reloading certificate, keeping previous one: ", err)
+			continue
+		}
+		log.Info("Certificate reloaded.")
+	}
+}
+
+// httpsRedirectHandler sends plain HTTP clients to the HTTPS listener
+func httpsRedirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// listenAndServeTLS serves handler over HTTPS, with optional HTTP redirect listener
+func listenAndServeTLS(server Server, handler http.Handler) error {
+	if err := serverCertificates.load(server.TLS); err != nil {
+		return err
+	}
+	go watchCertificateFiles(server.TLS)
+
+	if server.TLS.RedirectPort != 0 {
+		redirectAddr := net.JoinHostPort(server.Listen, strconv.Itoa(server.TLS.RedirectPort))
+		log.Info("Redirecting HTTP on ", redirectAddr, " to HTTPS")
+		go func() {
+			if err := http.ListenAndServe(redirectAddr, httpsRedirectHandler(server.Port)); err != nil {
+				log.Fatal("Error starting HTTP redirect server: ", err)
+			}
+		}()
+	}
+
+	httpServer := &http.Server{
+		Addr:      net.JoinHostPort(server.Listen, strconv.Itoa(server.Port)),
+		Handler:   handler,
+		TLSConfig: serverCertificates.tlsConfig(server.TLS),
+	}
+	// certificates come from TLSConfig
+	return httpServer.ListenAndServeTLS("", "")
+}
+
+func validateTLS(file string, root *yaml.Node, server Server) []configProblem {
+	var problems []configProblem
+	settings := server.TLS
+	problem := func(key string, message string) {
+		problems = append(problems, configProblem{file, nodeLine(findNode(root, "server", "tls", key)), message})
+	}
+
+	if (settings.CertFile == "") != (settings.KeyFile == "") {
+		problem("certFile", "both certFile and keyFile have to be set to enable TLS")
+	}
+	enabled := settings.CertFile != "" && settings.KeyFile != ""
+	if settings.ClientCAFile != "" && !enabled {
+		problem("clientCAFile", "clientCAFile requires certFile and keyFile")
+	}
+	if p := settings.RedirectPort; p < 0 || p > 65535 {
+		problem("redirectPort", fmt.Sprintf("port %d out of range 0 <-> 65535", p))
+	} else if p != 0 && !enabled {
+		problem("redirectPort", "redirectPort requires certFile and keyFile")
+	} else if p != 0 && p == server.Port {
+		problem("redirectPort", fmt.Sprintf("redirectPort %d has to differ from port", p))
+	}
+	return problems
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// issueCertificate returns certificate signed by parent, self-signed when parent is nil
+func issueCertificate(t *testing.T, name string, parent *tls.Certificate, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		ExtKeyUsage:           []x509.ExtKeyUsage{usage},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := template, any(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func writePEM(t *testing.T, path string, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientCertificateRequiredApartFromHealthEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueCertificate(t, "casavue test CA", nil, x509.ExtKeyUsageClientAuth)
+	otherCA := issueCertificate(t, "other CA", nil, x509.ExtKeyUsageClientAuth)
+	server := issueCertificate(t, "casavue", nil, x509.ExtKeyUsageServerAuth)
+	serverKey, _ := x509.MarshalECPrivateKey(server.PrivateKey.(*ecdsa.PrivateKey))
+
+	settings := TLS{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	writePEM(t, settings.CertFile, "CERTIFICATE", server.Certificate[0])
+	writePEM(t, settings.KeyFile, "EC PRIVATE KEY", serverKey)
+	writePEM(t, settings.ClientCAFile, "CERTIFICATE", ca.Certificate[0])
+
+	var store certificateStore
+	if err := store.load(settings); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/api/v1", func(w http.ResponseWriter, r *http.Request) {})
+	backend := httptest.NewUnstartedServer(clientCertificateHandler(mux))
+	backend.TLS = store.tlsConfig(settings)
+	backend.StartTLS()
+	defer backend.Close()
+
+	cases := []struct {
+		path       string
+		clientCert *tls.Certificate
+		status     int
+	}{
+		{"/healthz", nil, http.StatusOK},
+		{"/api/v1", nil, http.StatusForbidden},
+		{"/api/v1", &ca, http.StatusOK},
+		{"/api/v1", &otherCA, 0},
+	}
+	for _, c := range cases {
+		clientConfig := &tls.Config{InsecureSkipVerify: true}
+		if c.clientCert != nil {
+			cert := issueCertificate(t, "client", c.clientCert, x509.ExtKeyUsageClientAuth)
+			clientConfig.Certificates = []tls.Certificate{cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+
+		response, err := client.Get(backend.URL + c.path)
+		if c.status == 0 {
+			if err == nil {
+				response.Body.Close()
+				t.Errorf("%s: expected certificate of unknown CA to be rejected in handshake", c.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.path, err)
+			continue
+		}
+		response.Body.Close()
+		if response.StatusCode != c.status {
+			t.Errorf("%s with client certificate %v: got %d, expected %d", c.path, c.clientCert != nil, response.StatusCode, c.status)
+		}
+	}
+}